@@ -13,6 +13,8 @@ import (
 	"github.com/go-goim/core/pkg/log"
 
 	"github.com/go-goim/user-service/internal/app"
+	"github.com/go-goim/user-service/internal/consumer"
+	"github.com/go-goim/user-service/internal/job"
 	"github.com/go-goim/user-service/internal/service"
 )
 
@@ -32,10 +34,32 @@ func Main() {
 
 	cache.SetGlobalCache(cache.NewRedisCache(application.Redis))
 
+	jobRunner := job.NewRunner(
+		job.NewFriendRecommendJob(),
+		job.NewOutboxRelayJob(),
+		job.NewOutboxPurgeJob(),
+		job.NewCacheReconcileJob(),
+		job.NewMuteExpireJob(),
+		job.NewDissolvedGroupPurgeJob(),
+		job.NewMemberCountReconcileJob(),
+	)
+	jobRunner.Start()
+
+	retryEventDispatcher, err := consumer.NewRetryEventDispatcher()
+	if err != nil {
+		log.Fatal("NewRetryEventDispatcher got err", "error", err)
+	}
+
+	if err = retryEventDispatcher.Start(); err != nil {
+		log.Fatal("start retry event dispatcher got err", "error", err)
+	}
+
 	if err = application.Run(); err != nil {
 		log.Error("application run error", "error", err)
 	}
 
+	graceful.Register(retryEventDispatcher.Shutdown)
+	graceful.Register(jobRunner.Shutdown)
 	graceful.Register(application.Shutdown)
 	if err = graceful.Shutdown(context.TODO()); err != nil {
 		log.Error("graceful shutdown error", "error", err)
@@ -0,0 +1,146 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-goim/core/pkg/log"
+)
+
+const (
+	// PropertyRetryTimes is the message property records how many times the event has been retried by dispatcher.
+	PropertyRetryTimes = "retry_times"
+	// PropertyDeadLetterReason is the message property records why the event is sent to dead letter topic.
+	PropertyDeadLetterReason = "dead_letter_reason"
+	// maxRetryDelay caps the exponential backoff of retries.
+	maxRetryDelay = 10 * time.Minute
+)
+
+// EventHandler handles one kind of event, body is the raw json message body.
+type EventHandler func(ctx context.Context, body []byte) error
+
+// eventEnvelope is the common part of events, event field decides which handler to use.
+type eventEnvelope struct {
+	Event string `json:"event"`
+}
+
+// Dispatcher consumes topic and dispatches events to registered handlers by their event field.
+// Failed events are published to topic again with exponential backoff starting from retryDelay
+// until maxRetries reached, then published to dead letter topic.
+type Dispatcher struct {
+	queue           Queue
+	topic           string
+	deadLetterTopic string
+	maxRetries      int
+	retryDelay      time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]EventHandler
+}
+
+func NewDispatcher(queue Queue, topic, deadLetterTopic string, maxRetries int, retryDelay time.Duration) *Dispatcher {
+	return &Dispatcher{
+		queue:           queue,
+		topic:           topic,
+		deadLetterTopic: deadLetterTopic,
+		maxRetries:      maxRetries,
+		retryDelay:      retryDelay,
+		handlers:        make(map[string]EventHandler),
+	}
+}
+
+// Register registers handler of event, the last one wins if registered more than once.
+func (d *Dispatcher) Register(event string, handler EventHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[event] = handler
+}
+
+func (d *Dispatcher) Start() error {
+	if err := d.queue.Subscribe(d.topic, d.Handle); err != nil {
+		return err
+	}
+
+	return d.queue.Start()
+}
+
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	return d.queue.Shutdown(ctx)
+}
+
+// Handle dispatches message to handler.
+// It returns error only if the message cannot be retried or sent to dead letter topic,
+// so that queue will deliver it again.
+func (d *Dispatcher) Handle(ctx context.Context, msg *Message) error {
+	envelope := &eventEnvelope{}
+	if err := json.Unmarshal(msg.Body, envelope); err != nil {
+		return d.deadLetter(ctx, msg, fmt.Sprintf("invalid event body: %v", err))
+	}
+
+	d.mu.RLock()
+	handler, ok := d.handlers[envelope.Event]
+	d.mu.RUnlock()
+
+	if !ok {
+		return d.deadLetter(ctx, msg, fmt.Sprintf("no handler for event: %s", envelope.Event))
+	}
+
+	err := handler(ctx, msg.Body)
+	if err == nil {
+		return nil
+	}
+
+	log.Error("handle event error", "event", envelope.Event, "id", msg.ID, "err", err)
+	retryTimes, _ := strconv.Atoi(msg.GetProperty(PropertyRetryTimes)) // nolint: errcheck
+	if retryTimes >= d.maxRetries {
+		return d.deadLetter(ctx, msg, fmt.Sprintf("max retries reached: %v", err))
+	}
+
+	retry := &Message{
+		Topic:      d.topic,
+		Body:       msg.Body,
+		Properties: copyProperties(msg.Properties),
+		Delay:      d.backoff(retryTimes),
+	}
+	retry.SetProperty(PropertyRetryTimes, strconv.Itoa(retryTimes+1))
+	return d.queue.Publish(ctx, retry)
+}
+
+// backoff returns delay of the retry after given retry times, which doubles every time and is capped by maxRetryDelay.
+func (d *Dispatcher) backoff(retryTimes int) time.Duration {
+	delay := d.retryDelay
+	for i := 0; i < retryTimes && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, msg *Message, reason string) error {
+	log.Error("send event to dead letter topic", "id", msg.ID, "topic", d.deadLetterTopic, "reason", reason)
+	dl := &Message{
+		Topic:      d.deadLetterTopic,
+		Body:       msg.Body,
+		Properties: copyProperties(msg.Properties),
+	}
+	dl.SetProperty(PropertyDeadLetterReason, reason)
+	return d.queue.Publish(ctx, dl)
+}
+
+func copyProperties(p map[string]string) map[string]string {
+	m := make(map[string]string, len(p))
+	for k, v := range p {
+		m[k] = v
+	}
+
+	return m
+}
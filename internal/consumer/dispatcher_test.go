@@ -0,0 +1,173 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testTopic           = "test_topic"
+	testDeadLetterTopic = "test_topic_dlq"
+)
+
+func startTestDispatcher(t *testing.T, maxRetries int) (*Dispatcher, *MemoryQueue, chan *Message) {
+	t.Helper()
+
+	q := NewMemoryQueue(16)
+	d := NewDispatcher(q, testTopic, testDeadLetterTopic, maxRetries, time.Millisecond)
+
+	deadLetters := make(chan *Message, 1)
+	err := q.Subscribe(testDeadLetterTopic, func(_ context.Context, msg *Message) error {
+		deadLetters <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe dead letter topic: %v", err)
+	}
+
+	return d, q, deadLetters
+}
+
+func waitDeadLetter(t *testing.T, deadLetters chan *Message) *Message {
+	t.Helper()
+
+	select {
+	case msg := <-deadLetters:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message not sent to dead letter topic")
+		return nil
+	}
+}
+
+func TestDispatcher_RetryThenDeadLetter(t *testing.T) {
+	const maxRetries = 3
+
+	d, q, deadLetters := startTestDispatcher(t, maxRetries)
+
+	var calls int32
+	d.Register("always_fail", func(_ context.Context, _ []byte) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("handler failed")
+	})
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("start dispatcher: %v", err)
+	}
+	defer d.Shutdown(context.Background()) // nolint: errcheck
+
+	err := q.Publish(context.Background(), &Message{Topic: testTopic, Body: []byte(`{"event":"always_fail"}`)})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msg := waitDeadLetter(t, deadLetters)
+	if got := msg.GetProperty(PropertyRetryTimes); got != "3" {
+		t.Errorf("retry times = %q, want %q", got, "3")
+	}
+
+	if msg.GetProperty(PropertyDeadLetterReason) == "" {
+		t.Error("dead letter reason is empty")
+	}
+
+	if got := atomic.LoadInt32(&calls); got != maxRetries+1 {
+		t.Errorf("handler called %d times, want %d", got, maxRetries+1)
+	}
+}
+
+func TestDispatcher_RetryThenSucceed(t *testing.T) {
+	d, q, deadLetters := startTestDispatcher(t, 3)
+
+	var calls int32
+	handled := make(chan struct{})
+	d.Register("fail_once", func(_ context.Context, _ []byte) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("handler failed")
+		}
+
+		close(handled)
+		return nil
+	})
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("start dispatcher: %v", err)
+	}
+	defer d.Shutdown(context.Background()) // nolint: errcheck
+
+	err := q.Publish(context.Background(), &Message{Topic: testTopic, Body: []byte(`{"event":"fail_once"}`)})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case <-handled:
+	case msg := <-deadLetters:
+		t.Fatalf("message sent to dead letter topic: %s", msg.GetProperty(PropertyDeadLetterReason))
+	case <-time.After(time.Second):
+		t.Fatal("message not retried")
+	}
+}
+
+func TestDispatcher_DeadLetterWithoutRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid body", body: `not json`},
+		{name: "no handler", body: `{"event":"unknown"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, q, deadLetters := startTestDispatcher(t, 3)
+			if err := d.Start(); err != nil {
+				t.Fatalf("start dispatcher: %v", err)
+			}
+			defer d.Shutdown(context.Background()) // nolint: errcheck
+
+			err := q.Publish(context.Background(), &Message{Topic: testTopic, Body: []byte(tt.body)})
+			if err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+
+			msg := waitDeadLetter(t, deadLetters)
+			if got := msg.GetProperty(PropertyRetryTimes); got != "" {
+				t.Errorf("retry times = %q, want empty", got)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(NewMemoryQueue(1), testTopic, testDeadLetterTopic, 3, time.Second)
+
+	tests := []struct {
+		retryTimes int
+		want       time.Duration
+	}{
+		{retryTimes: 0, want: time.Second},
+		{retryTimes: 1, want: 2 * time.Second},
+		{retryTimes: 3, want: 8 * time.Second},
+		{retryTimes: 20, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := d.backoff(tt.retryTimes); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.retryTimes, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryQueue_PublishNotBlockWhenFull(t *testing.T) {
+	q := NewMemoryQueue(1)
+	if err := q.Publish(context.Background(), &Message{Topic: testTopic}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if err := q.Publish(context.Background(), &Message{Topic: testTopic}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("publish to full queue got err %v, want %v", err, ErrQueueFull)
+	}
+}
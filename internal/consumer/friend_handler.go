@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+
+	friendpb "github.com/go-goim/api/user/friend/v1"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/event"
+)
+
+// NewSetFriendStatusToCacheHandler returns handler of event.RetryEventSetFriendStatusToCache.
+// Relation is checked again before setting cache, because users may not be friends any more
+// when the event is consumed.
+func NewSetFriendStatusToCacheHandler(friendDao *dao.FriendDao) EventHandler {
+	return func(ctx context.Context, body []byte) error {
+		payload := &event.SetFriendStatusToCachePayload{}
+		if err := json.Unmarshal(body, payload); err != nil {
+			return err
+		}
+
+		var (
+			uid  = types.ID(payload.UID)
+			fuid = types.ID(payload.FriendUID)
+		)
+
+		me, err := friendDao.GetFriendByStatus(ctx, uid, fuid, int(friendpb.FriendStatus_FRIEND))
+		if err != nil {
+			return err
+		}
+
+		friend, err := friendDao.GetFriendByStatus(ctx, fuid, uid, int(friendpb.FriendStatus_FRIEND))
+		if err != nil {
+			return err
+		}
+
+		if me == nil || friend == nil {
+			return friendDao.DeleteFriendStatusFromCache(ctx, uid, fuid)
+		}
+
+		return friendDao.SetFriendStatusToCache(ctx, uid, fuid)
+	}
+}
@@ -0,0 +1,137 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-goim/core/pkg/log"
+)
+
+// ErrQueueFull is returned by MemoryQueue.Publish when buffer is full.
+var ErrQueueFull = errors.New("memory queue is full")
+
+// MemoryQueue is an in-memory implementation of Queue, used in tests and local development.
+// Messages published before Start are buffered, messages of unsubscribed topics are dropped.
+// Publish never blocks, because handlers publish retries from the only consumer goroutine.
+type MemoryQueue struct {
+	mu       sync.RWMutex
+	handlers map[string]MessageHandler
+	ch       chan *Message
+	seq      int64
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+var _ Queue = &MemoryQueue{}
+
+func NewMemoryQueue(bufferSize int) *MemoryQueue {
+	return &MemoryQueue{
+		handlers: make(map[string]MessageHandler),
+		ch:       make(chan *Message, bufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+func (q *MemoryQueue) Subscribe(topic string, handler MessageHandler) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.handlers[topic]; ok {
+		return fmt.Errorf("topic %s already subscribed", topic)
+	}
+
+	q.handlers[topic] = handler
+	return nil
+}
+
+// Publish buffers message and returns ErrQueueFull if buffer is full.
+// Delayed message is buffered after delay in its own goroutine, it waits for free buffer instead of failing.
+func (q *MemoryQueue) Publish(_ context.Context, msg *Message) error {
+	if msg.ID == "" {
+		msg.ID = strconv.FormatInt(atomic.AddInt64(&q.seq, 1), 10)
+	}
+
+	if msg.Delay > 0 {
+		time.AfterFunc(msg.Delay, func() {
+			select {
+			case q.ch <- msg:
+			case <-q.done:
+			}
+		})
+		return nil
+	}
+
+	select {
+	case q.ch <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *MemoryQueue) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-q.ch:
+				q.handle(ctx, msg)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (q *MemoryQueue) handle(ctx context.Context, msg *Message) {
+	q.mu.RLock()
+	handler, ok := q.handlers[msg.Topic]
+	q.mu.RUnlock()
+
+	if !ok {
+		log.Debug("drop message of unsubscribed topic", "topic", msg.Topic, "id", msg.ID)
+		return
+	}
+
+	if err := handler(ctx, msg); err != nil {
+		log.Error("handle message error", "topic", msg.Topic, "id", msg.ID, "err", err)
+	}
+}
+
+// Len returns count of messages waiting to be handled.
+func (q *MemoryQueue) Len() int {
+	return len(q.ch)
+}
+
+func (q *MemoryQueue) Shutdown(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+
+	q.cancel()
+	close(q.done)
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
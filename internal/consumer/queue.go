@@ -0,0 +1,49 @@
+package consumer
+
+import (
+	"context"
+	"time"
+)
+
+// Message is the mq message consumed by dispatcher, it hides the detail of mq implementation.
+type Message struct {
+	ID         string
+	Topic      string
+	Body       []byte
+	Properties map[string]string
+	// Delay is the min duration before published message can be consumed, zero means at once.
+	// Queue may round it up to the delay it supports.
+	Delay time.Duration
+}
+
+// GetProperty returns property of message, empty string if not exists.
+func (m *Message) GetProperty(key string) string {
+	if m.Properties == nil {
+		return ""
+	}
+
+	return m.Properties[key]
+}
+
+// SetProperty sets property of message.
+func (m *Message) SetProperty(key, value string) {
+	if m.Properties == nil {
+		m.Properties = make(map[string]string)
+	}
+
+	m.Properties[key] = value
+}
+
+// MessageHandler handles message consumed from queue.
+// Returning error means the message is not consumed and may be delivered again by queue.
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// Queue is the abstraction of mq used by consumer subsystem.
+type Queue interface {
+	// Subscribe registers handler of topic, must be called before Start.
+	Subscribe(topic string, handler MessageHandler) error
+	// Publish sends message to topic.
+	Publish(ctx context.Context, msg *Message) error
+	Start() error
+	Shutdown(ctx context.Context) error
+}
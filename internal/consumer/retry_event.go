@@ -0,0 +1,51 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+
+	"github.com/go-goim/user-service/internal/app"
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/event"
+)
+
+var (
+	mqNameServers         []string
+	retryEventGroup       string
+	retryEventMaxRetries  int
+	retryEventRetryDelay  time.Duration
+	retryEventMemoryQueue bool
+)
+
+func init() {
+	cmd.GlobalFlagSet.StringSliceVar(&mqNameServers, "mq-name-server", []string{"127.0.0.1:9876"},
+		"name server addresses of rocketmq")
+	cmd.GlobalFlagSet.StringVar(&retryEventGroup, "retry-event-group", "user_service_retry_event",
+		"consumer group of retry event topic")
+	cmd.GlobalFlagSet.IntVar(&retryEventMaxRetries, "retry-event-max-retries", 3,
+		"max retry times of retry event before sent to dead letter topic")
+	cmd.GlobalFlagSet.DurationVar(&retryEventRetryDelay, "retry-event-retry-delay", 5*time.Second,
+		"delay of the first retry of retry event, doubled for every next retry")
+	cmd.GlobalFlagSet.BoolVar(&retryEventMemoryQueue, "retry-event-memory-queue", false,
+		"use in-memory queue instead of rocketmq to consume retry events, only for local development")
+}
+
+// NewRetryEventDispatcher creates dispatcher consumes event.RetryEventTopic with all retry event handlers registered.
+func NewRetryEventDispatcher() (*Dispatcher, error) {
+	var queue Queue
+	if retryEventMemoryQueue {
+		queue = NewMemoryQueue(1024)
+	} else {
+		q, err := NewRocketMQQueue(mqNameServers, retryEventGroup, app.GetApplication().Producer)
+		if err != nil {
+			return nil, err
+		}
+		queue = q
+	}
+
+	d := NewDispatcher(queue, event.RetryEventTopic, event.RetryEventDeadLetterTopic, retryEventMaxRetries,
+		retryEventRetryDelay)
+	d.Register(event.RetryEventSetFriendStatusToCache, NewSetFriendStatusToCacheHandler(dao.GetUserRelationDao()))
+	return d, nil
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/rocketmq-client-go/v2"
+	mqconsumer "github.com/apache/rocketmq-client-go/v2/consumer"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/event"
+)
+
+// RocketMQQueue implements Queue with rocketmq push consumer and the producer of application.
+type RocketMQQueue struct {
+	consumer rocketmq.PushConsumer
+	producer event.Producer
+}
+
+var _ Queue = &RocketMQQueue{}
+
+func NewRocketMQQueue(nameServers []string, group string, producer event.Producer) (*RocketMQQueue, error) {
+	c, err := rocketmq.NewPushConsumer(
+		mqconsumer.WithGroupName(group),
+		mqconsumer.WithNameServer(nameServers),
+		mqconsumer.WithConsumerModel(mqconsumer.Clustering),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RocketMQQueue{
+		consumer: c,
+		producer: producer,
+	}, nil
+}
+
+func (q *RocketMQQueue) Subscribe(topic string, handler MessageHandler) error {
+	return q.consumer.Subscribe(topic, mqconsumer.MessageSelector{},
+		func(ctx context.Context, msgs ...*primitive.MessageExt) (mqconsumer.ConsumeResult, error) {
+			for _, m := range msgs {
+				msg := &Message{
+					ID:         m.MsgId,
+					Topic:      m.Topic,
+					Body:       m.Body,
+					Properties: m.GetProperties(),
+				}
+
+				if err := handler(ctx, msg); err != nil {
+					log.Error("handle message error", "topic", m.Topic, "id", m.MsgId, "err", err)
+					return mqconsumer.ConsumeRetryLater, err
+				}
+			}
+
+			return mqconsumer.ConsumeSuccess, nil
+		})
+}
+
+func (q *RocketMQQueue) Publish(ctx context.Context, msg *Message) error {
+	m := primitive.NewMessage(msg.Topic, msg.Body)
+	if len(msg.Properties) > 0 {
+		m.WithProperties(msg.Properties)
+	}
+
+	if msg.Delay > 0 {
+		m.WithDelayTimeLevel(delayTimeLevel(msg.Delay))
+	}
+
+	_, err := q.producer.SendSync(ctx, m)
+	return err
+}
+
+func (q *RocketMQQueue) Start() error {
+	return q.consumer.Start()
+}
+
+func (q *RocketMQQueue) Shutdown(_ context.Context) error {
+	return q.consumer.Shutdown()
+}
+
+// delayLevels are the default message delay levels of rocketmq broker, level n is delayLevels[n-1].
+var delayLevels = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute, 5 * time.Minute, 6 * time.Minute,
+	7 * time.Minute, 8 * time.Minute, 9 * time.Minute, 10 * time.Minute, 20 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour,
+}
+
+// delayTimeLevel returns the min delay level not shorter than d, or the max level if d is longer than all levels.
+func delayTimeLevel(d time.Duration) int {
+	for i, l := range delayLevels {
+		if l >= d {
+			return i + 1
+		}
+	}
+
+	return len(delayLevels)
+}
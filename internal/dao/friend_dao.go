@@ -3,9 +3,11 @@ package dao
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
+	redisv8 "github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 
 	friendpb "github.com/go-goim/api/user/friend/v1"
@@ -14,10 +16,13 @@ import (
 	"github.com/go-goim/core/pkg/log"
 	"github.com/go-goim/core/pkg/types"
 
+	"github.com/go-goim/user-service/internal/app"
 	"github.com/go-goim/user-service/internal/data"
 )
 
-type FriendDao struct{}
+type FriendDao struct {
+	rdb *redisv8.Client
+}
 
 var (
 	friendDao     *FriendDao
@@ -26,7 +31,9 @@ var (
 
 func GetUserRelationDao() *FriendDao {
 	friendDaoOnce.Do(func() {
-		friendDao = &FriendDao{}
+		friendDao = &FriendDao{
+			rdb: app.GetApplication().Redis,
+		}
 	})
 	return friendDao
 }
@@ -100,6 +107,8 @@ func (d *FriendDao) CheckIsFriend(ctx context.Context, uid, friendUID types.ID)
 	return true, nil
 }
 
+const friendStatusKeyPrefix = "friend_status:"
+
 func friendStatusKey(uid, friendUID types.ID) string {
 	if uid.Int64() > friendUID.Int64() {
 		return fmt.Sprintf("friend_status:%d:%d", friendUID.Int64(), uid.Int64())
@@ -107,6 +116,21 @@ func friendStatusKey(uid, friendUID types.ID) string {
 	return fmt.Sprintf("friend_status:%d:%d", uid.Int64(), friendUID.Int64())
 }
 
+// ParseFriendStatusKey parses uid and friend uid from friend status cache key.
+func ParseFriendStatusKey(key string) (uid, friendUID types.ID, ok bool) {
+	var a, b int64
+	if _, err := fmt.Sscanf(key, "friend_status:%d:%d", &a, &b); err != nil {
+		return 0, 0, false
+	}
+
+	return types.ID(a), types.ID(b), true
+}
+
+// ScanFriendStatusKeys scans friend status cache keys from cursor, next cursor is 0 when scan finished.
+func (d *FriendDao) ScanFriendStatusKeys(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	return d.rdb.Scan(ctx, cursor, friendStatusKeyPrefix+"*", count).Result()
+}
+
 // GetFriendStatusFromCache get friend status from cache.
 // cache key: sort(uid, friend_uid), so that there is no duplicated key, only one record between two users.
 // cache value: 1 as constant.
@@ -142,11 +166,28 @@ func (d *FriendDao) GetFriends(ctx context.Context, uid types.ID) ([]*data.Frien
 	return userRelationList, nil
 }
 
+// GetFriendsByStatus list relations of given uid with given status by page.
+func (d *FriendDao) GetFriendsByStatus(ctx context.Context, uid types.ID, status friendpb.FriendStatus,
+	page, pageSize int) ([]*data.Friend, error) {
+	userRelationList := make([]*data.Friend, 0)
+	err := db.GetDBFromCtx(ctx).Where("uid = ? AND status = ?", uid, status).
+		Order("id").Scopes(Paginate(page, pageSize)).Find(&userRelationList).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return userRelationList, nil
+}
+
 func (d *FriendDao) CreateFriend(ctx context.Context, friend *data.Friend) error {
 	friend.CreatedAt = time.Now().Unix()
 	friend.UpdatedAt = time.Now().Unix()
 
-	return db.GetDBFromCtx(ctx).Create(friend).Error
+	if err := db.GetDBFromCtx(ctx).Create(friend).Error; err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (d *FriendDao) UpdateFriendStatus(ctx context.Context, userRelation *data.Friend) error {
@@ -170,3 +211,134 @@ func (d *FriendDao) CountFriends(ctx context.Context, uid types.ID) (int64, erro
 
 	return count, nil
 }
+
+func (d *FriendDao) CountFriendsByStatus(ctx context.Context, uid types.ID, status friendpb.FriendStatus) (int64, error) {
+	var count int64
+	err := db.GetDBFromCtx(ctx).Model(&data.Friend{}).Where("uid = ? AND status = ?", uid, status).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// friendSetPlaceholder is the member to keep friend uid set exists in redis when user has no friend.
+const friendSetPlaceholder = "-"
+
+func friendSetKey(uid types.ID) string {
+	return fmt.Sprintf("friend_set:%d", uid.Int64())
+}
+
+// ListFriendUIDs returns uid list of users who are friend with given uid in both directions,
+// which means relations blocked by either side are excluded.
+func (d *FriendDao) ListFriendUIDs(ctx context.Context, uid types.ID) ([]types.ID, error) {
+	result := make([]types.ID, 0)
+	tx := db.GetDBFromCtx(ctx).Table("friend AS f1").Select("f1.friend_uid").
+		Joins("JOIN friend AS f2 ON f2.uid = f1.friend_uid AND f2.friend_uid = f1.uid").
+		Where("f1.uid = ? AND f1.status = ? AND f2.status = ?",
+			uid, friendpb.FriendStatus_FRIEND, friendpb.FriendStatus_FRIEND).
+		Find(&result)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return result, nil
+}
+
+// ListMutualFriendUIDs returns uid list of friends shared by uid and otherUID.
+// It uses friend uid sets in redis and loads missing sets from db.
+func (d *FriendDao) ListMutualFriendUIDs(ctx context.Context, uid, otherUID types.ID) ([]types.ID, error) {
+	for _, id := range []types.ID{uid, otherUID} {
+		if err := d.loadFriendSetToCache(ctx, id); err != nil {
+			log.Error("load friend set to cache error", "uid", id, "err", err)
+			return d.listMutualFriendUIDsFromDB(ctx, uid, otherUID)
+		}
+	}
+
+	members, err := d.rdb.SInter(ctx, friendSetKey(uid), friendSetKey(otherUID)).Result()
+	if err != nil {
+		log.Error("get mutual friends from cache error", "uid", uid, "other_uid", otherUID, "err", err)
+		return d.listMutualFriendUIDsFromDB(ctx, uid, otherUID)
+	}
+
+	result := make([]types.ID, 0, len(members))
+	for _, m := range members {
+		i, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			// placeholder member of empty set
+			continue
+		}
+
+		result = append(result, types.ID(i))
+	}
+
+	return result, nil
+}
+
+func (d *FriendDao) listMutualFriendUIDsFromDB(ctx context.Context, uid, otherUID types.ID) ([]types.ID, error) {
+	uids, err := d.ListFriendUIDs(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	otherUIDs, err := d.ListFriendUIDs(ctx, otherUID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[types.ID]struct{}, len(uids))
+	for _, id := range uids {
+		set[id] = struct{}{}
+	}
+
+	result := make([]types.ID, 0)
+	for _, id := range otherUIDs {
+		if _, ok := set[id]; ok {
+			result = append(result, id)
+		}
+	}
+
+	return result, nil
+}
+
+// loadFriendSetToCache loads friend uid set of given uid to cache if it is not cached.
+// An empty set is stored with a placeholder member, so that users without friends won't hit db every time.
+func (d *FriendDao) loadFriendSetToCache(ctx context.Context, uid types.ID) error {
+	key := friendSetKey(uid)
+	n, err := d.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	uids, err := d.ListFriendUIDs(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	members := make([]interface{}, 0, len(uids)+1)
+	members = append(members, friendSetPlaceholder)
+	for _, id := range uids {
+		members = append(members, id.Int64())
+	}
+
+	_, err = d.rdb.TxPipelined(ctx, func(pipe redisv8.Pipeliner) error {
+		pipe.SAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, time.Duration(data.FriendSetCacheExpire)*time.Second)
+		return nil
+	})
+
+	return err
+}
+
+// InvalidFriendSetCache deletes friend uid sets of both users of a relation.
+// It must be called after the relation change is committed, otherwise a concurrent reader may load
+// the old relation back into cache. Error is only logged because the set will expire finally.
+func (d *FriendDao) InvalidFriendSetCache(ctx context.Context, uid, friendUID types.ID) {
+	if err := d.rdb.Del(ctx, friendSetKey(uid), friendSetKey(friendUID)).Err(); err != nil {
+		log.Error("delete friend set from cache error", "uid", uid, "friend_uid", friendUID, "err", err)
+	}
+}
@@ -0,0 +1,275 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	redisv8 "github.com/go-redis/redis/v8"
+	"gorm.io/gorm/clause"
+
+	friendpb "github.com/go-goim/api/user/friend/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/app"
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type FriendRecommendationDao struct {
+	rdb *redisv8.Client
+}
+
+var (
+	friendRecommendationDao     *FriendRecommendationDao
+	friendRecommendationDaoOnce sync.Once
+)
+
+func GetFriendRecommendationDao() *FriendRecommendationDao {
+	friendRecommendationDaoOnce.Do(func() {
+		friendRecommendationDao = &FriendRecommendationDao{
+			rdb: app.GetApplication().Redis,
+		}
+	})
+	return friendRecommendationDao
+}
+
+// ListRecommendations list not dismissed recommendations of given uid order by score.
+// Relations may be changed since recommendations were computed, so targets which are friend of uid
+// or blocked in either direction are excluded before pagination.
+func (d *FriendRecommendationDao) ListRecommendations(ctx context.Context, uid types.ID, page, pageSize int) (
+	[]*data.FriendRecommendation, error) {
+	list := make([]*data.FriendRecommendation, 0)
+	err := db.GetDBFromCtx(ctx).Table("friend_recommendation AS r").Select("r.*").
+		Where("r.uid = ? AND r.dismissed = ?", uid, false).
+		Where("NOT EXISTS (SELECT 1 FROM friend AS f WHERE "+
+			"((f.uid = r.uid AND f.friend_uid = r.target_uid) OR (f.uid = r.target_uid AND f.friend_uid = r.uid)) "+
+			"AND f.status IN (?))",
+			[]friendpb.FriendStatus{friendpb.FriendStatus_FRIEND, friendpb.FriendStatus_BLOCKED}).
+		Order("r.score DESC").Order("r.id").Scopes(Paginate(page, pageSize)).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// DismissRecommendation marks recommendation as dismissed.
+// Record will be created if the target has not been recommended yet, so that it won't be recommended later.
+func (d *FriendRecommendationDao) DismissRecommendation(ctx context.Context, uid, targetUID types.ID) error {
+	now := time.Now().Unix()
+	r := &data.FriendRecommendation{
+		UID:       uid,
+		TargetUID: targetUID,
+		Dismissed: true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return db.GetDBFromCtx(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}, {Name: "target_uid"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"dismissed":  true,
+			"updated_at": now,
+		}),
+	}).Create(r).Error
+}
+
+// ListDismissedTargetUIDs returns target uid list dismissed by given uid.
+func (d *FriendRecommendationDao) ListDismissedTargetUIDs(ctx context.Context, uid types.ID) ([]types.ID, error) {
+	result := make([]types.ID, 0)
+	tx := db.GetDBFromCtx(ctx).Model(&data.FriendRecommendation{}).Select("target_uid").
+		Where("uid = ? AND dismissed = ?", uid, true).Find(&result)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return result, nil
+}
+
+// ReplaceRecommendations replaces all not dismissed recommendations of given uid.
+// Should be called in transaction.
+func (d *FriendRecommendationDao) ReplaceRecommendations(ctx context.Context, uid types.ID,
+	list []*data.FriendRecommendation) error {
+	tx := db.GetDBFromCtx(ctx).Where("uid = ? AND dismissed = ?", uid, false).Delete(&data.FriendRecommendation{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return db.GetDBFromCtx(ctx).CreateInBatches(list, len(list)).Error
+}
+
+type uidCount struct {
+	UID   types.ID `gorm:"column:uid"`
+	Count int      `gorm:"column:cnt"`
+}
+
+// CountMutualFriendCandidates returns friends of friends of given uid with the count of mutual friends.
+func (d *FriendRecommendationDao) CountMutualFriendCandidates(ctx context.Context, uid types.ID) (
+	map[types.ID]int, error) {
+	rows := make([]*uidCount, 0)
+	tx := db.GetDBFromCtx(ctx).Table("friend AS f1").Select("f2.friend_uid AS uid, COUNT(*) AS cnt").
+		Joins("JOIN friend AS f2 ON f2.uid = f1.friend_uid").
+		Where("f1.uid = ? AND f1.status = ? AND f2.status = ? AND f2.friend_uid <> ?",
+			uid, friendpb.FriendStatus_FRIEND, friendpb.FriendStatus_FRIEND, uid).
+		Group("f2.friend_uid").Find(&rows)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return uidCountsToMap(rows), nil
+}
+
+// CountSharedGroupCandidates returns members of groups given uid is in with the count of shared groups.
+// Groups have more than maxGroupSize members are skipped.
+func (d *FriendRecommendationDao) CountSharedGroupCandidates(ctx context.Context, uid types.ID, maxGroupSize int) (
+	map[types.ID]int, error) {
+	rows := make([]*uidCount, 0)
+	tx := db.GetDBFromCtx(ctx).Table("group_member AS gm1").Select("gm2.uid AS uid, COUNT(*) AS cnt").
+		Joins("JOIN `group` AS g ON g.gid = gm1.gid").
+		Joins("JOIN group_member AS gm2 ON gm2.gid = gm1.gid").
+		Where("gm1.uid = ? AND gm2.uid <> ? AND g.member_count <= ?", uid, uid, maxGroupSize).
+		Group("gm2.uid").Find(&rows)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return uidCountsToMap(rows), nil
+}
+
+func uidCountsToMap(rows []*uidCount) map[types.ID]int {
+	m := make(map[types.ID]int, len(rows))
+	for _, r := range rows {
+		m[r.UID] = r.Count
+	}
+
+	return m
+}
+
+// ListExcludedUIDs returns uid list which must not be recommended to given uid:
+// friends, users blocked by uid or blocking uid, and users rejected friend request from uid.
+func (d *FriendRecommendationDao) ListExcludedUIDs(ctx context.Context, uid types.ID) ([]types.ID, error) {
+	var (
+		related  = make([]types.ID, 0)
+		blocking = make([]types.ID, 0)
+		rejected = make([]types.ID, 0)
+	)
+
+	// friends and blocked by uid, stranger can be recommended again.
+	tx := db.GetDBFromCtx(ctx).Model(&data.Friend{}).Select("friend_uid").
+		Where("uid = ? AND status IN (?)", uid,
+			[]friendpb.FriendStatus{friendpb.FriendStatus_FRIEND, friendpb.FriendStatus_BLOCKED}).
+		Find(&related)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	tx = db.GetDBFromCtx(ctx).Model(&data.Friend{}).Select("uid").
+		Where("friend_uid = ? AND status = ?", uid, friendpb.FriendStatus_BLOCKED).Find(&blocking)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	tx = db.GetDBFromCtx(ctx).Model(&data.FriendRequest{}).Select("friend_uid").
+		Where("uid = ? AND status = ?", uid, friendpb.FriendRequestStatus_REJECTED).Find(&rejected)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	result := make([]types.ID, 0, len(related)+len(blocking)+len(rejected))
+	result = append(result, related...)
+	result = append(result, blocking...)
+	result = append(result, rejected...)
+	return result, nil
+}
+
+const activeUserKey = "active_users"
+
+// MarkUserActive records uid as active now, only active users get their recommendations refreshed.
+func (d *FriendRecommendationDao) MarkUserActive(ctx context.Context, uid types.ID) error {
+	return d.rdb.ZAdd(ctx, activeUserKey, &redisv8.Z{Score: float64(time.Now().Unix()), Member: uid.Int64()}).Err()
+}
+
+// TrimInactiveUsers removes users not active since given unix time.
+func (d *FriendRecommendationDao) TrimInactiveUsers(ctx context.Context, since int64) error {
+	return d.rdb.ZRemRangeByScore(ctx, activeUserKey, "-inf", "("+strconv.FormatInt(since, 10)).Err()
+}
+
+// ScanActiveUsers iterates active users by cursor, returns uid list and next cursor.
+// Iteration is finished when returned cursor is 0.
+func (d *FriendRecommendationDao) ScanActiveUsers(ctx context.Context, cursor uint64, count int64) (
+	[]types.ID, uint64, error) {
+	// result of ZSCAN is member and score in turn.
+	kvs, next, err := d.rdb.ZScan(ctx, activeUserKey, cursor, "", count).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]types.ID, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		uid, err1 := strconv.ParseInt(kvs[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+
+		result = append(result, types.ID(uid))
+	}
+
+	return result, next, nil
+}
+
+func profileViewKey(uid types.ID) string {
+	return fmt.Sprintf("profile_view:%d", uid.Int64())
+}
+
+// AddProfileView records that uid viewed profile of viewedUID.
+// Views are stored in sorted set with view time as score, and views older than data.ProfileViewExpire are dropped.
+func (d *FriendRecommendationDao) AddProfileView(ctx context.Context, uid, viewedUID types.ID) error {
+	var (
+		key    = profileViewKey(uid)
+		now    = time.Now().Unix()
+		expire = time.Duration(data.ProfileViewExpire) * time.Second
+	)
+
+	_, err := d.rdb.TxPipelined(ctx, func(pipe redisv8.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redisv8.Z{Score: float64(now), Member: viewedUID.Int64()})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now-data.ProfileViewExpire, 10))
+		pipe.Expire(ctx, key, expire)
+		return nil
+	})
+
+	return err
+}
+
+// ListRecentViewedUIDs returns uid list viewed by given uid recently.
+func (d *FriendRecommendationDao) ListRecentViewedUIDs(ctx context.Context, uid types.ID) ([]types.ID, error) {
+	minScore := strconv.FormatInt(time.Now().Unix()-data.ProfileViewExpire, 10)
+	members, err := d.rdb.ZRangeByScore(ctx, profileViewKey(uid), &redisv8.ZRangeBy{
+		Min: minScore,
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		if err == redisv8.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result := make([]types.ID, 0, len(members))
+	for _, m := range members {
+		i, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, types.ID(i))
+	}
+
+	return result, nil
+}
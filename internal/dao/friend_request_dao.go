@@ -59,6 +59,19 @@ func (d *FriendRequestDao) GetFriendRequestByID(ctx context.Context, id uint64)
 	return &fr, nil
 }
 
+func (d *FriendRequestDao) GetFriendRequestsByIDs(ctx context.Context, ids []uint64) ([]*data.FriendRequest, error) {
+	var frs []*data.FriendRequest
+	if len(ids) == 0 {
+		return frs, nil
+	}
+
+	if err := db.GetDBFromCtx(ctx).Where("id IN (?)", ids).Find(&frs).Error; err != nil {
+		return nil, err
+	}
+
+	return frs, nil
+}
+
 func (d *FriendRequestDao) GetFriendRequests(ctx context.Context, uid types.ID, status int) ([]*data.FriendRequest, error) {
 	var frs []*data.FriendRequest
 	// query friend request send to me.
@@ -69,6 +82,18 @@ func (d *FriendRequestDao) GetFriendRequests(ctx context.Context, uid types.ID,
 	return frs, nil
 }
 
+// ListFriendRequestsToUser list at most limit friend requests send to uid in given status order by id.
+func (d *FriendRequestDao) ListFriendRequestsToUser(ctx context.Context, uid types.ID, status int, limit int) (
+	[]*data.FriendRequest, error) {
+	var frs []*data.FriendRequest
+	err := db.GetDBFromCtx(ctx).Where("friend_uid = ? AND status = ?", uid, status).Order("id").Limit(limit).Find(&frs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return frs, nil
+}
+
 func (d *FriendRequestDao) UpdateFriendRequest(ctx context.Context, fr *data.FriendRequest) error {
 	return db.GetDBFromCtx(ctx).Model(fr).UpdateColumns(map[string]interface{}{
 		"status":     fr.Status,
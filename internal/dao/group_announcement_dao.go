@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type GroupAnnouncementDao struct {
+}
+
+var (
+	groupAnnouncementDao     *GroupAnnouncementDao
+	groupAnnouncementDaoOnce sync.Once
+)
+
+func GetGroupAnnouncementDao() *GroupAnnouncementDao {
+	groupAnnouncementDaoOnce.Do(func() {
+		groupAnnouncementDao = &GroupAnnouncementDao{}
+	})
+	return groupAnnouncementDao
+}
+
+func (d *GroupAnnouncementDao) CreateGroupAnnouncement(ctx context.Context, a *data.GroupAnnouncement) error {
+	a.CreatedAt = time.Now().Unix()
+	a.UpdatedAt = time.Now().Unix()
+	return db.GetDBFromCtx(ctx).Create(a).Error
+}
+
+func (d *GroupAnnouncementDao) GetGroupAnnouncement(ctx context.Context, id uint64) (*data.GroupAnnouncement, error) {
+	var a data.GroupAnnouncement
+	if err := db.GetDBFromCtx(ctx).Where("id = ?", id).First(&a).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// GetCurrentGroupAnnouncement returns the latest pinned announcement of group,
+// or the latest one if none pinned.
+func (d *GroupAnnouncementDao) GetCurrentGroupAnnouncement(ctx context.Context, gid types.ID) (
+	*data.GroupAnnouncement, error) {
+	var a data.GroupAnnouncement
+	err := db.GetDBFromCtx(ctx).Where("gid = ?", gid).Order("pinned DESC").Order("id DESC").First(&a).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// ListGroupAnnouncements list announcement history of group by page, latest first.
+func (d *GroupAnnouncementDao) ListGroupAnnouncements(ctx context.Context, gid types.ID, page, pageSize int) (
+	[]*data.GroupAnnouncement, error) {
+	list := make([]*data.GroupAnnouncement, 0)
+	err := db.GetDBFromCtx(ctx).Where("gid = ?", gid).Order("id DESC").
+		Scopes(Paginate(page, pageSize)).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (d *GroupAnnouncementDao) UpdateGroupAnnouncementPinned(ctx context.Context, id uint64, pinned bool) error {
+	return db.GetDBFromCtx(ctx).Model(&data.GroupAnnouncement{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"pinned":     pinned,
+			"updated_at": time.Now().Unix(),
+		}).Error
+}
+
+// MarkGroupAnnouncementRead records uid has read announcement and increases read count of announcement.
+// Read count is not increased if uid has read it before. Should be called in transaction.
+func (d *GroupAnnouncementDao) MarkGroupAnnouncementRead(ctx context.Context, id uint64, uid types.ID) error {
+	r := &data.GroupAnnouncementRead{
+		AnnouncementID: id,
+		UID:            uid,
+		CreatedAt:      time.Now().Unix(),
+	}
+
+	tx := db.GetDBFromCtx(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(r)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return nil
+	}
+
+	return db.GetDBFromCtx(ctx).Model(&data.GroupAnnouncement{}).Where("id = ?", id).
+		Update("read_count", gorm.Expr("read_count + 1")).Error
+}
@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type GroupBanDao struct {
+}
+
+var (
+	groupBanDao     *GroupBanDao
+	groupBanDaoOnce sync.Once
+)
+
+func GetGroupBanDao() *GroupBanDao {
+	groupBanDaoOnce.Do(func() {
+		groupBanDao = &GroupBanDao{}
+	})
+	return groupBanDao
+}
+
+// UpsertGroupBans creates bans, existing bans of same users are overwritten.
+func (d *GroupBanDao) UpsertGroupBans(ctx context.Context, bans ...*data.GroupBan) error {
+	if len(bans) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for _, b := range bans {
+		b.CreatedAt = now
+		b.UpdatedAt = now
+	}
+
+	return db.GetDBFromCtx(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "gid"}, {Name: "uid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"operator_uid", "reason", "expire_at", "updated_at"}),
+	}).CreateInBatches(bans, len(bans)).Error
+}
+
+func (d *GroupBanDao) DeleteGroupBan(ctx context.Context, gid, uid types.ID) error {
+	return db.GetDBFromCtx(ctx).Where("gid = ? AND uid = ?", gid, uid).Delete(&data.GroupBan{}).Error
+}
+
+// ListGroupBans list active bans of group by page, latest first.
+func (d *GroupBanDao) ListGroupBans(ctx context.Context, gid types.ID, page, pageSize int) ([]*data.GroupBan, error) {
+	list := make([]*data.GroupBan, 0)
+	err := db.GetDBFromCtx(ctx).Where("gid = ?", gid).
+		Where("expire_at = 0 OR expire_at > ?", time.Now().Unix()).
+		Order("id DESC").Scopes(Paginate(page, pageSize)).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ListBannedUIDs returns uid list in given uids which are banned by group now.
+func (d *GroupBanDao) ListBannedUIDs(ctx context.Context, gid types.ID, uids []types.ID) ([]types.ID, error) {
+	result := make([]types.ID, 0)
+	if len(uids) == 0 {
+		return result, nil
+	}
+
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupBan{}).Select("uid").
+		Where("gid = ? AND uid IN (?)", gid, uids).
+		Where("expire_at = 0 OR expire_at > ?", time.Now().Unix()).Find(&result)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return result, nil
+}
@@ -2,13 +2,22 @@ package dao
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	redisv8 "github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/cache"
 	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/log"
 	"github.com/go-goim/core/pkg/types"
+	"github.com/go-goim/core/pkg/util"
 	"github.com/go-goim/user-service/internal/app"
 	"github.com/go-goim/user-service/internal/data"
 )
@@ -44,9 +53,71 @@ func (d *GroupDao) GetGroup(ctx context.Context, id uint64) (*data.Group, error)
 	return group, nil
 }
 
+// GetGroupByGID get group by gid, dissolved group is treated as not exist.
 func (d *GroupDao) GetGroupByGID(ctx context.Context, gid types.ID) (*data.Group, error) {
 	group := &data.Group{}
-	tx := db.GetDBFromCtx(ctx).Where("gid = ?", gid).First(group)
+	tx := db.GetDBFromCtx(ctx).Where("gid = ? AND status <> ?", gid, grouppb.GroupStatus_Dissolved).First(group)
+	if tx.Error != nil {
+		if tx.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	return group, nil
+}
+
+func groupCacheKey(gid types.ID) string {
+	return fmt.Sprintf("group:%d", gid.Int64())
+}
+
+// GetGroupByGIDWithCache get not dissolved group by gid from cache first, it is used on hot path like sending message.
+// Status and mute time are invalidated by InvalidGroupCache once changed,
+// other fields may be stale for at most data.GroupCacheExpire seconds.
+func (d *GroupDao) GetGroupByGIDWithCache(ctx context.Context, gid types.ID) (*data.Group, error) {
+	val, err := cache.Get(ctx, groupCacheKey(gid))
+	if err == nil {
+		group := &data.Group{}
+		if err = json.Unmarshal(val, group); err == nil {
+			return group, nil
+		}
+	}
+
+	if err != cache.ErrCacheMiss {
+		log.Error("get group from cache error", "gid", gid, "err", err)
+	}
+
+	group, err := d.GetGroupByGID(ctx, gid)
+	if err != nil || group == nil {
+		return group, err
+	}
+
+	if val, err = json.Marshal(group); err != nil {
+		return nil, err
+	}
+
+	expire := time.Duration(data.GroupCacheExpire+util.RandIntn(data.GroupCacheExpire/10)) * time.Second
+	if err = cache.Set(ctx, groupCacheKey(gid), val, expire); err != nil {
+		log.Error("set group to cache error", "gid", gid, "err", err)
+	}
+
+	return group, nil
+}
+
+// InvalidGroupCache deletes group cached by GetGroupByGIDWithCache, error is only logged.
+// Should be called after status or mute time of group changed and transaction committed.
+func (d *GroupDao) InvalidGroupCache(ctx context.Context, gid types.ID) {
+	if err := cache.Delete(ctx, groupCacheKey(gid)); err != nil {
+		log.Error("delete group from cache error", "gid", gid, "err", err)
+	}
+}
+
+// GetGroupByGIDForUpdate get group by gid and locks the row until transaction ends.
+// Should be called in transaction.
+func (d *GroupDao) GetGroupByGIDForUpdate(ctx context.Context, gid types.ID) (*data.Group, error) {
+	group := &data.Group{}
+	tx := db.GetDBFromCtx(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("gid = ? AND status <> ?", gid, grouppb.GroupStatus_Dissolved).First(group)
 	if tx.Error != nil {
 		if tx.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -59,7 +130,7 @@ func (d *GroupDao) GetGroupByGID(ctx context.Context, gid types.ID) (*data.Group
 
 func (d *GroupDao) ListGroups(ctx context.Context, gids []types.ID) ([]*data.Group, error) {
 	groups := make([]*data.Group, 0)
-	tx := db.GetDBFromCtx(ctx).Where("gid in (?)", gids).Find(&groups)
+	tx := db.GetDBFromCtx(ctx).Where("gid in (?) AND status <> ?", gids, grouppb.GroupStatus_Dissolved).Find(&groups)
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
@@ -76,8 +147,23 @@ func (d *GroupDao) CreateGroup(ctx context.Context, group *data.Group) error {
 	return nil
 }
 
+// UpdateGroup updates info columns editable by owner and admins of not dissolved group.
+// Other columns like member count and mute state are changed concurrently, so they are never written here.
 func (d *GroupDao) UpdateGroup(ctx context.Context, group *data.Group) error {
-	tx := db.GetDBFromCtx(ctx).Save(group)
+	group.UpdatedAt = time.Now().Unix()
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND status <> ?", group.GID, grouppb.GroupStatus_Dissolved).
+		Updates(map[string]interface{}{
+			"name":          group.Name,
+			"description":   group.Description,
+			"avatar":        group.Avatar,
+			"join_policy":   group.JoinPolicy,
+			"join_question": group.JoinQuestion,
+			"join_answer":   group.JoinAnswer,
+			"public":        group.Public,
+			"tags":          group.Tags,
+			"updated_at":    group.UpdatedAt,
+		})
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -85,8 +171,77 @@ func (d *GroupDao) UpdateGroup(ctx context.Context, group *data.Group) error {
 	return nil
 }
 
-func (d *GroupDao) DeleteGroup(ctx context.Context, group *data.Group) error {
-	tx := db.GetDBFromCtx(ctx).Delete(group)
+// GetDissolvedGroupByGID get dissolved group by gid.
+func (d *GroupDao) GetDissolvedGroupByGID(ctx context.Context, gid types.ID) (*data.Group, error) {
+	group := &data.Group{}
+	tx := db.GetDBFromCtx(ctx).Where("gid = ? AND status = ?", gid, grouppb.GroupStatus_Dissolved).First(group)
+	if tx.Error != nil {
+		if tx.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	return group, nil
+}
+
+// DissolveGroup marks group as dissolved, the group row is kept as tombstone.
+// It returns false if group is already dissolved.
+func (d *GroupDao) DissolveGroup(ctx context.Context, gid types.ID) (bool, error) {
+	now := time.Now().Unix()
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND status <> ?", gid, grouppb.GroupStatus_Dissolved).
+		Updates(map[string]interface{}{
+			"status":       grouppb.GroupStatus_Dissolved,
+			"dissolved_at": now,
+			"member_count": 0,
+			"updated_at":   now,
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// RestoreGroup restores dissolved group to active status with given member count.
+// It returns false if group is not dissolved.
+func (d *GroupDao) RestoreGroup(ctx context.Context, gid types.ID, memberCount int) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND status = ?", gid, grouppb.GroupStatus_Dissolved).
+		Updates(map[string]interface{}{
+			"status":       grouppb.GroupStatus_Active,
+			"mute_until":   0,
+			"dissolved_at": 0,
+			"member_count": memberCount,
+			"updated_at":   time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// UpdateGroupOwner changes owner of group from given uid to another uid.
+// It returns false if owner of group is not from uid anymore, e.g. transferred concurrently.
+func (d *GroupDao) UpdateGroupOwner(ctx context.Context, gid, fromUID, toUID types.ID) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).Where("gid = ? AND owner_uid = ?", gid, fromUID).
+		Update("owner_uid", toUID)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// UpdateGroupMute updates status and unmute time of group.
+func (d *GroupDao) UpdateGroupMute(ctx context.Context, gid types.ID, status grouppb.GroupStatus, muteUntil int64) error {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).Where("gid = ?", gid).Updates(map[string]interface{}{
+		"status":     status,
+		"mute_until": muteUntil,
+		"updated_at": time.Now().Unix(),
+	})
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -94,23 +249,152 @@ func (d *GroupDao) DeleteGroup(ctx context.Context, group *data.Group) error {
 	return nil
 }
 
+// ListExpiredMutedGroups list silent groups whose mute expired at given time.
+func (d *GroupDao) ListExpiredMutedGroups(ctx context.Context, now int64, limit int) ([]*data.Group, error) {
+	groups := make([]*data.Group, 0)
+	tx := db.GetDBFromCtx(ctx).Where("status = ? AND mute_until > 0 AND mute_until <= ?",
+		grouppb.GroupStatus_Silent, now).Limit(limit).Find(&groups)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groups, nil
+}
+
+// UnmuteExpiredGroup unmutes group if mute expired at given time.
+// It returns false if group is not muted or muted again with a new time.
+func (d *GroupDao) UnmuteExpiredGroup(ctx context.Context, gid types.ID, now int64) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND status = ? AND mute_until > 0 AND mute_until <= ?", gid, grouppb.GroupStatus_Silent, now).
+		Updates(map[string]interface{}{
+			"status":     grouppb.GroupStatus_Active,
+			"mute_until": 0,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// CountOwnedGroups counts groups of given tier owned by uid, dissolved groups are excluded.
+func (d *GroupDao) CountOwnedGroups(ctx context.Context, ownerUID types.ID, tier grouppb.GroupTier) (int64, error) {
+	var count int64
+	err := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("owner_uid = ? AND tier = ? AND status <> ?", ownerUID, tier, grouppb.GroupStatus_Dissolved).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// UpdateGroupTier moves group to given tier with its max members.
+// It returns false if group not exists or current member count exceeds max members of the tier.
+func (d *GroupDao) UpdateGroupTier(ctx context.Context, gid types.ID, tier grouppb.GroupTier, maxMembers int) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND status <> ? AND member_count <= ?", gid, grouppb.GroupStatus_Dissolved, maxMembers).
+		Updates(map[string]interface{}{
+			"tier":        tier,
+			"max_members": maxMembers,
+			"updated_at":  time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// ListGroupsAfter list groups whose id greater than afterID ordered by id, dissolved groups are excluded.
+func (d *GroupDao) ListGroupsAfter(ctx context.Context, afterID uint64, limit int) ([]*data.Group, error) {
+	groups := make([]*data.Group, 0)
+	tx := db.GetDBFromCtx(ctx).Where("id > ? AND status <> ?", afterID, grouppb.GroupStatus_Dissolved).
+		Order("id").Limit(limit).Find(&groups)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groups, nil
+}
+
+// FixGroupMemberCount sets member count of group to actual count only if it is still the recorded one,
+// so that count changed concurrently won't be overwritten. It returns false if count has changed.
+func (d *GroupDao) FixGroupMemberCount(ctx context.Context, gid types.ID, recorded, actual int) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND member_count = ?", gid, recorded).
+		Update("member_count", actual)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// likeEscaper escapes wildcards of LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchGroupsOption is the filter of searching public groups.
+type SearchGroupsOption struct {
+	// Keyword matches name, description or tags of group.
+	Keyword string
+	// Tag matches one of tags of group exactly.
+	Tag string
+	// ActiveAfter is the time after which groups are treated as active, active groups are ranked first.
+	ActiveAfter int64
+	Page        int
+	PageSize    int
+}
+
+// SearchGroups searches public groups, dissolved groups and full groups are excluded.
+// Active groups are ranked before inactive ones, then groups are ranked by member count and active time.
+func (d *GroupDao) SearchGroups(ctx context.Context, opt *SearchGroupsOption) ([]*data.Group, error) {
+	groups := make([]*data.Group, 0)
+	tx := db.GetDBFromCtx(ctx).
+		Where("public = ? AND status <> ? AND member_count < max_members", true, grouppb.GroupStatus_Dissolved)
+	if opt.Keyword != "" {
+		kw := "%" + likeEscaper.Replace(opt.Keyword) + "%"
+		tx = tx.Where("(name LIKE ? OR description LIKE ? OR tags LIKE ?)", kw, kw, kw)
+	}
+
+	if opt.Tag != "" {
+		tx = tx.Where("tags LIKE ?", "%,"+likeEscaper.Replace(opt.Tag)+",%")
+	}
+
+	tx = tx.Order(fmt.Sprintf("active_at > %d DESC", opt.ActiveAfter)).
+		Order("member_count DESC").Order("active_at DESC").Order("id").
+		Scopes(Paginate(opt.Page, opt.PageSize)).Find(&groups)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groups, nil
+}
+
+// TouchGroupActiveAt updates active time of group if it is not updated since given time.
+func (d *GroupDao) TouchGroupActiveAt(ctx context.Context, gid types.ID, now, notUpdatedSince int64) error {
+	return db.GetDBFromCtx(ctx).Model(&data.Group{}).
+		Where("gid = ? AND active_at < ?", gid, notUpdatedSince).
+		UpdateColumn("active_at", now).Error
+}
+
 // IncrGroupMemberCount incr group member count by given increase.
 // It will check if after increased group member count is greater than max group member count,
 // if so, it will return false.
+// Max members is read from the row, so that tier changed concurrently is respected.
 func (d *GroupDao) IncrGroupMemberCount(ctx context.Context, g *data.Group, increase uint) (bool, error) {
 	tx := db.GetDBFromCtx(ctx).Model(&data.Group{}).Where("gid = ?", g.GID).
-		Where("member_count + ? <= ?", increase, g.MaxMembers).
+		Where("member_count + ? <= max_members", increase).
 		Update("member_count", gorm.Expr("member_count + ?", increase))
 	if tx.Error != nil {
-		// not found means group member count is greater than max group member count,
-		//  because we have already if group exists
-		if tx.Error == gorm.ErrRecordNotFound {
-			return false, nil
-		}
 		return false, tx.Error
 	}
 
-	return true, nil
+	// no row affected means the condition on member count is not satisfied,
+	//  because we have already checked if group exists
+	return tx.RowsAffected > 0, nil
 }
 
 // DecrGroupMemberCount decr group member count by given decrease.
@@ -121,12 +405,10 @@ func (d *GroupDao) DecrGroupMemberCount(ctx context.Context, g *data.Group, decr
 		Where("member_count - ? >= 0", decrease).
 		Update("member_count", gorm.Expr("member_count - ?", decrease))
 	if tx.Error != nil {
-		// not found means group member count is less than 0, because we have already if group exists
-		if tx.Error == gorm.ErrRecordNotFound {
-			return false, nil
-		}
 		return false, tx.Error
 	}
 
-	return true, nil
+	// no row affected means the condition on member count is not satisfied,
+	//  because we have already checked if group exists
+	return tx.RowsAffected > 0, nil
 }
@@ -0,0 +1,85 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type GroupInviteDao struct {
+}
+
+var (
+	groupInviteDao     *GroupInviteDao
+	groupInviteDaoOnce sync.Once
+)
+
+func GetGroupInviteDao() *GroupInviteDao {
+	groupInviteDaoOnce.Do(func() {
+		groupInviteDao = &GroupInviteDao{}
+	})
+	return groupInviteDao
+}
+
+func (d *GroupInviteDao) CreateGroupInvite(ctx context.Context, invite *data.GroupInvite) error {
+	invite.CreatedAt = time.Now().Unix()
+	invite.UpdatedAt = time.Now().Unix()
+	return db.GetDBFromCtx(ctx).Create(invite).Error
+}
+
+func (d *GroupInviteDao) GetGroupInviteByToken(ctx context.Context, token string) (*data.GroupInvite, error) {
+	var invite data.GroupInvite
+	if err := db.GetDBFromCtx(ctx).Where("token = ?", token).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// ListGroupInvites list usable invites of group.
+func (d *GroupInviteDao) ListGroupInvites(ctx context.Context, gid types.ID) ([]*data.GroupInvite, error) {
+	list := make([]*data.GroupInvite, 0)
+	err := db.GetDBFromCtx(ctx).Where("gid = ? AND revoked = ?", gid, false).
+		Where("expire_at = 0 OR expire_at > ?", time.Now().Unix()).
+		Where("max_uses = 0 OR used_count < max_uses").
+		Order("id").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (d *GroupInviteDao) RevokeGroupInvite(ctx context.Context, invite *data.GroupInvite) error {
+	return db.GetDBFromCtx(ctx).Model(invite).Updates(map[string]interface{}{
+		"revoked":    true,
+		"updated_at": time.Now().Unix(),
+	}).Error
+}
+
+// IncrGroupInviteUsedCount increases used count of invite by one.
+// It returns false if invite is revoked, expired or used up, so that concurrent joins won't exceed max uses.
+func (d *GroupInviteDao) IncrGroupInviteUsedCount(ctx context.Context, invite *data.GroupInvite) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupInvite{}).Where("id = ? AND revoked = ?", invite.ID, false).
+		Where("expire_at = 0 OR expire_at > ?", time.Now().Unix()).
+		Where("max_uses = 0 OR used_count < max_uses").
+		Updates(map[string]interface{}{
+			"used_count": gorm.Expr("used_count + 1"),
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
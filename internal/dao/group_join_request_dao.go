@@ -0,0 +1,126 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type GroupJoinRequestDao struct {
+}
+
+var (
+	groupJoinRequestDao     *GroupJoinRequestDao
+	groupJoinRequestDaoOnce sync.Once
+)
+
+func GetGroupJoinRequestDao() *GroupJoinRequestDao {
+	groupJoinRequestDaoOnce.Do(func() {
+		groupJoinRequestDao = &GroupJoinRequestDao{}
+	})
+	return groupJoinRequestDao
+}
+
+// CreatePendingGroupJoinRequest creates pending join request, nothing is created and false is returned
+// if uid already has a pending request to the group, which is guaranteed by unique key on pending_uid.
+func (d *GroupJoinRequestDao) CreatePendingGroupJoinRequest(ctx context.Context, r *data.GroupJoinRequest) (bool, error) {
+	r.CreatedAt = time.Now().Unix()
+	r.UpdatedAt = time.Now().Unix()
+	tx := db.GetDBFromCtx(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(r)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+func (d *GroupJoinRequestDao) GetGroupJoinRequestByID(ctx context.Context, id uint64) (*data.GroupJoinRequest, error) {
+	var r data.GroupJoinRequest
+	if err := db.GetDBFromCtx(ctx).Where("id = ?", id).First(&r).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// GetPendingGroupJoinRequest get pending join request of uid to group.
+func (d *GroupJoinRequestDao) GetPendingGroupJoinRequest(ctx context.Context, gid, uid types.ID) (
+	*data.GroupJoinRequest, error) {
+	var r data.GroupJoinRequest
+	err := db.GetDBFromCtx(ctx).Where("gid = ? AND uid = ? AND status = ?",
+		gid, uid, grouppb.GroupJoinRequest_StatusPending).First(&r).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// ListPendingGroupJoinRequests list pending join requests of group by page, earliest first.
+func (d *GroupJoinRequestDao) ListPendingGroupJoinRequests(ctx context.Context, gid types.ID, page, pageSize int) (
+	[]*data.GroupJoinRequest, error) {
+	list := make([]*data.GroupJoinRequest, 0)
+	err := db.GetDBFromCtx(ctx).Where("gid = ? AND status = ?", gid, grouppb.GroupJoinRequest_StatusPending).
+		Order("id").Scopes(Paginate(page, pageSize)).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ListPendingGroupJoinRequestsForUpdate list pending join requests of group in given ids and locks them
+// until transaction ends, so that one request won't be handled twice. Should be called in transaction.
+func (d *GroupJoinRequestDao) ListPendingGroupJoinRequestsForUpdate(ctx context.Context, gid types.ID,
+	ids []uint64) ([]*data.GroupJoinRequest, error) {
+	list := make([]*data.GroupJoinRequest, 0)
+	if len(ids) == 0 {
+		return list, nil
+	}
+
+	err := db.GetDBFromCtx(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("gid = ? AND id IN (?) AND status = ?", gid, ids, grouppb.GroupJoinRequest_StatusPending).
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// UpdateGroupJoinRequestsStatus updates status of pending join requests of group in given ids,
+// requests of other groups are never updated. Count of updated requests is returned.
+func (d *GroupJoinRequestDao) UpdateGroupJoinRequestsStatus(ctx context.Context, gid types.ID, ids []uint64,
+	status grouppb.GroupJoinRequest_Status, operatorUID types.ID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupJoinRequest{}).
+		Where("gid = ? AND id IN (?) AND status = ?", gid, ids, grouppb.GroupJoinRequest_StatusPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"operator_uid": operatorUID,
+			"updated_at":   time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return tx.RowsAffected, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	redisv8 "github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 
 	grouppb "github.com/go-goim/api/user/group/v1"
@@ -13,10 +16,13 @@ import (
 	"github.com/go-goim/core/pkg/db"
 	"github.com/go-goim/core/pkg/log"
 	"github.com/go-goim/core/pkg/types"
+	"github.com/go-goim/user-service/internal/app"
 	"github.com/go-goim/user-service/internal/data"
 )
 
-type GroupMemberDao struct{}
+type GroupMemberDao struct {
+	rdb *redisv8.Client
+}
 
 var (
 	groupMemberDao     *GroupMemberDao
@@ -25,7 +31,9 @@ var (
 
 func GetGroupMemberDao() *GroupMemberDao {
 	groupMemberDaoOnce.Do(func() {
-		groupMemberDao = &GroupMemberDao{}
+		groupMemberDao = &GroupMemberDao{
+			rdb: app.GetApplication().Redis,
+		}
 	})
 	return groupMemberDao
 }
@@ -43,52 +51,448 @@ func (d *GroupMemberDao) GetGroupMember(ctx context.Context, id uint64) (*data.G
 	return groupMember, nil
 }
 
-func (d *GroupMemberDao) IsMemberOfGroup(ctx context.Context, gid, uid types.ID) (*data.GroupMember, error) {
+// IsMemberOfGroup returns member status of uid in group, ok is false if uid is not member of group.
+// Only status is cached, load member by GetGroupMemberByGIDUID if type or mute time is needed.
+func (d *GroupMemberDao) IsMemberOfGroup(ctx context.Context, gid, uid types.ID) (
+	status grouppb.GroupMember_Status, ok bool, err error) {
 	// load from cache
-	status, err := d.IsMemberOfGroupFromCache(ctx, gid, uid)
+	cached, err := d.IsMemberOfGroupFromCache(ctx, gid, uid)
 	if err != nil && err != cache.ErrCacheMiss {
-		return nil, err
+		return 0, false, err
 	}
 
 	if err == nil {
-		return &data.GroupMember{
-			GID:    gid,
-			UID:    uid,
-			Status: grouppb.GroupMember_Status(status),
-		}, nil
+		if cached == data.GroupMemberStatusNotMember {
+			return 0, false, nil
+		}
+
+		return grouppb.GroupMember_Status(cached), true, nil
 	}
 
 	gm := &data.GroupMember{}
 	tx := db.GetDBFromCtx(ctx).Where("gid = ? AND uid = ?", gid, uid).First(gm)
 	if tx.Error != nil {
 		if tx.Error == gorm.ErrRecordNotFound {
-			return nil, nil
+			// negative cache, so that non-members won't hit db every time.
+			if err = d.fillMemberStatusToCache(ctx, gid, uid, data.GroupMemberStatusNotMember); err != nil {
+				log.Error("fillMemberStatusToCache", "gid", gid, "uid", uid, "err", err)
+			}
+			return 0, false, nil
 		}
-		return nil, tx.Error
+		return 0, false, tx.Error
 	}
 
-	if err := d.SetMemberStatusToCache(ctx, gid, uid, int(gm.Status)); err != nil {
-		log.Error("SetMemberStatusToCache", "gid", gid, "uid", uid, "err", err)
+	if err = d.fillMemberStatusToCache(ctx, gid, uid, int(gm.Status)); err != nil {
+		log.Error("fillMemberStatusToCache", "gid", gid, "uid", uid, "err", err)
 	}
 
-	return gm, nil
+	return gm.Status, true, nil
+}
+
+const groupMembersKeyPrefix = "group_members_"
+
+func groupMembersKey(gid types.ID) string {
+	return fmt.Sprintf("group_members_%s", gid)
 }
 
-// IsMemberOfGroupFromCache return member status in group of given uid. 0: active , 1: silent
+// ParseGroupMembersKey parses gid from group members cache key.
+func ParseGroupMembersKey(key string) (types.ID, bool) {
+	if !strings.HasPrefix(key, groupMembersKeyPrefix) {
+		return 0, false
+	}
+
+	i, err := strconv.ParseInt(strings.TrimPrefix(key, groupMembersKeyPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return types.ID(i), true
+}
+
+// IsMemberOfGroupFromCache return member status in group of given uid.
+// 0: active , 1: silent, data.GroupMemberStatusNotMember: not member.
+// cache.ErrCacheMiss is returned if uid is not cached.
 func (d *GroupMemberDao) IsMemberOfGroupFromCache(ctx context.Context, gid, uid types.ID) (int, error) {
-	key := fmt.Sprintf("group_members_%s", gid)
-	b, err := cache.GetFromHash(ctx, key, uid.String())
+	key := groupMembersKey(gid)
+	val, err := d.rdb.HGet(ctx, key, uid.String()).Result()
 	if err != nil {
+		if err == redisv8.Nil {
+			return 0, cache.ErrCacheMiss
+		}
 		return 0, err
 	}
 
-	i, _ := strconv.Atoi(string(b)) // nolint: errcheck
+	i, _ := strconv.Atoi(val) // nolint: errcheck
 	return i, nil
 }
 
 func (d *GroupMemberDao) SetMemberStatusToCache(ctx context.Context, gid, uid types.ID, status int) error {
-	key := fmt.Sprintf("group_members_%s", gid)
-	return cache.SetToHash(ctx, key, uid.String(), []byte(strconv.Itoa(status)))
+	return d.SetMembersStatusToCache(ctx, gid, map[types.ID]int{uid: status})
+}
+
+// setMembersStatusScript sets fields of group members cache, expire time is only set when cache is created,
+// so that cache of active group still expires.
+// KEYS[1] is group members cache key, ARGV[1] is expire seconds, the others are field and value in turn.
+var setMembersStatusScript = redisv8.NewScript(`
+redis.call("HSET", KEYS[1], unpack(ARGV, 2))
+if redis.call("TTL", KEYS[1]) < 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// fillMemberStatusScript is setMembersStatusScript but only sets the field if it is absent.
+// KEYS[1] is group members cache key, ARGV[1] is expire seconds, ARGV[2] is field and ARGV[3] is value.
+var fillMemberStatusScript = redisv8.NewScript(`
+local n = redis.call("HSETNX", KEYS[1], ARGV[2], ARGV[3])
+if redis.call("TTL", KEYS[1]) < 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// SetMembersStatusToCache sets status of many members to group members cache after membership changed in db.
+func (d *GroupMemberDao) SetMembersStatusToCache(ctx context.Context, gid types.ID, statuses map[types.ID]int) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(statuses)*2+1)
+	args = append(args, data.GroupMembersCacheExpire)
+	for uid, status := range statuses {
+		args = append(args, uid.String(), strconv.Itoa(status))
+	}
+
+	return setMembersStatusScript.Run(ctx, d.rdb, []string{groupMembersKey(gid)}, args...).Err()
+}
+
+// fillMemberStatusToCache caches status loaded from db only if uid is not cached,
+// so that status written by concurrent membership change is never overwritten by the stale one.
+func (d *GroupMemberDao) fillMemberStatusToCache(ctx context.Context, gid, uid types.ID, status int) error {
+	return fillMemberStatusScript.Run(ctx, d.rdb, []string{groupMembersKey(gid)},
+		data.GroupMembersCacheExpire, uid.String(), strconv.Itoa(status)).Err()
+}
+
+// SetNonMembersToCache marks given uids as non-members in group members cache.
+func (d *GroupMemberDao) SetNonMembersToCache(ctx context.Context, gid types.ID, uids ...types.ID) error {
+	statuses := make(map[types.ID]int, len(uids))
+	for _, uid := range uids {
+		statuses[uid] = data.GroupMemberStatusNotMember
+	}
+
+	return d.SetMembersStatusToCache(ctx, gid, statuses)
+}
+
+// ScanGroupMembersKeys scans group members cache keys from cursor, next cursor is 0 when scan finished.
+func (d *GroupMemberDao) ScanGroupMembersKeys(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	return d.rdb.Scan(ctx, cursor, groupMembersKeyPrefix+"*", count).Result()
+}
+
+// ScanMemberStatusFromCache scans member status in group members cache from cursor.
+// Fields can not be parsed are returned as invalid fields.
+func (d *GroupMemberDao) ScanMemberStatusFromCache(ctx context.Context, gid types.ID, cursor uint64, count int64) (
+	statuses map[types.ID]int, invalid []string, next uint64, err error) {
+	kvs, next, err := d.rdb.HScan(ctx, groupMembersKey(gid), cursor, "", count).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	statuses = make(map[types.ID]int, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		uid, err1 := strconv.ParseInt(kvs[i], 10, 64)
+		status, err2 := strconv.Atoi(kvs[i+1])
+		if err1 != nil || err2 != nil {
+			invalid = append(invalid, kvs[i])
+			continue
+		}
+
+		statuses[types.ID(uid)] = status
+	}
+
+	return statuses, invalid, next, nil
+}
+
+// DeleteMemberStatusFromCache deletes given fields from group members cache.
+func (d *GroupMemberDao) DeleteMemberStatusFromCache(ctx context.Context, gid types.ID, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return d.rdb.HDel(ctx, groupMembersKey(gid), fields...).Err()
+}
+
+// DeleteGroupMembersCache deletes whole group members cache of given group, including member uid set.
+// Member uid set being built is dropped too, so that the builder gives up.
+func (d *GroupMemberDao) DeleteGroupMembersCache(ctx context.Context, gid types.ID) error {
+	return d.rdb.Del(ctx, groupMembersKey(gid), groupMemberUIDSetKey(gid), groupMemberUIDSetBuildLockKey(gid),
+		groupMemberUIDSetBuildingKey(gid), groupMemberUIDSetRemovedKey(gid)).Err()
+}
+
+const (
+	// groupMemberUIDSetLoadBatch is count of members loaded from db per query when building member uid set.
+	groupMemberUIDSetLoadBatch = 1000
+	// groupMemberUIDSetBuildTimeout is the max duration of building member uid set, the build lock expires after it.
+	groupMemberUIDSetBuildTimeout = time.Minute
+)
+
+func groupMemberUIDSetKey(gid types.ID) string {
+	return fmt.Sprintf("group_member_uids_%s", gid)
+}
+
+// groupMemberUIDSetBuildLockKey is held by the only builder of member uid set, the value is token of builder.
+func groupMemberUIDSetBuildLockKey(gid types.ID) string {
+	return fmt.Sprintf("group_member_uids_%s_lock", gid)
+}
+
+// groupMemberUIDSetBuildingKey is the member uid set being built, it is renamed to member uid set when done.
+func groupMemberUIDSetBuildingKey(gid types.ID) string {
+	return fmt.Sprintf("group_member_uids_%s_building", gid)
+}
+
+// groupMemberUIDSetRemovedKey records uids removed while building,
+// they may be loaded from db before removed and are dropped from the set when done.
+func groupMemberUIDSetRemovedKey(gid types.ID) string {
+	return fmt.Sprintf("group_member_uids_%s_removed", gid)
+}
+
+func groupMemberUIDSetKeys(gid types.ID) []string {
+	return []string{
+		groupMemberUIDSetKey(gid),
+		groupMemberUIDSetBuildLockKey(gid),
+		groupMemberUIDSetBuildingKey(gid),
+		groupMemberUIDSetRemovedKey(gid),
+	}
+}
+
+// addToGroupMemberUIDSetScript adds members to member uid set only if the set exists,
+// so that a set only contains part of members is never created.
+// Members are added to the set being built as well, because the builder may have loaded db before they joined.
+// KEYS are groupMemberUIDSetKeys, ARGV are score and member in turn.
+var addToGroupMemberUIDSetScript = redisv8.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("ZADD", KEYS[1], unpack(ARGV))
+end
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	redis.call("ZADD", KEYS[3], unpack(ARGV))
+	for i = 2, #ARGV, 2 do
+		redis.call("SREM", KEYS[4], ARGV[i])
+	end
+end
+return 0
+`)
+
+// removeFromGroupMemberUIDSetScript removes members from member uid set and the set being built,
+// members removed while building are recorded, because the builder may load them from db before removed.
+// KEYS are groupMemberUIDSetKeys, ARGV[1] is expire seconds of removed set, the others are members.
+var removeFromGroupMemberUIDSetScript = redisv8.NewScript(`
+local members = {unpack(ARGV, 2)}
+redis.call("ZREM", KEYS[1], unpack(members))
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	redis.call("ZREM", KEYS[3], unpack(members))
+	redis.call("SADD", KEYS[4], unpack(members))
+	redis.call("EXPIRE", KEYS[4], ARGV[1])
+end
+return 0
+`)
+
+// buildGroupMemberUIDSetScript adds members loaded from db to the set being built if build lock is still held.
+// KEYS are groupMemberUIDSetKeys, ARGV[1] is token of builder, ARGV[2] is expire seconds,
+// the others are score and member in turn. It returns 0 if lock is lost.
+var buildGroupMemberUIDSetScript = redisv8.NewScript(`
+if redis.call("GET", KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call("ZADD", KEYS[3], unpack(ARGV, 3))
+redis.call("EXPIRE", KEYS[3], ARGV[2])
+return 1
+`)
+
+// finishGroupMemberUIDSetScript drops members removed while building and renames the set being built
+// to member uid set if build lock is still held, then releases the lock.
+// KEYS are groupMemberUIDSetKeys, ARGV[1] is token of builder, ARGV[2] is expire seconds of member uid set.
+// It returns 1 if renamed, 0 if nothing built and -1 if lock is lost.
+var finishGroupMemberUIDSetScript = redisv8.NewScript(`
+if redis.call("GET", KEYS[2]) ~= ARGV[1] then
+	return -1
+end
+local removed = redis.call("SMEMBERS", KEYS[4])
+if #removed > 0 then
+	redis.call("ZREM", KEYS[3], unpack(removed))
+end
+local result = 0
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	redis.call("RENAME", KEYS[3], KEYS[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+	result = 1
+end
+redis.call("DEL", KEYS[2], KEYS[4])
+return result
+`)
+
+// LoadGroupMemberUIDSet builds member uid set of group in cache if it is not cached.
+// Member uid set is a sorted set with uid as member and id of group member row as score,
+// which keeps all members of group, so that it can be used to page member uids and check membership.
+// False is returned if group has no member and nothing cached, or the set is being built by others.
+func (d *GroupMemberDao) LoadGroupMemberUIDSet(ctx context.Context, gid types.ID) (bool, error) {
+	keys := groupMemberUIDSetKeys(gid)
+	n, err := d.rdb.Exists(ctx, keys[0]).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if n > 0 {
+		return true, nil
+	}
+
+	// only one builder per group, members added or removed while building are applied to the set being built
+	// by AddToGroupMemberUIDSet and RemoveFromGroupMemberUIDSet, so that no change is lost.
+	token := types.NewID().String()
+	ok, err := d.rdb.SetNX(ctx, keys[1], token, groupMemberUIDSetBuildTimeout).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	var (
+		expire  = int(groupMemberUIDSetBuildTimeout.Seconds())
+		afterID uint64
+	)
+	// leftover of builder whose lock expired.
+	if err = d.rdb.Del(ctx, keys[2], keys[3]).Err(); err != nil {
+		d.rdb.Del(ctx, keys[1])
+		return false, err
+	}
+
+	for {
+		gmList, err1 := d.ListGroupMemberUIDsAfter(ctx, gid, afterID, groupMemberUIDSetLoadBatch)
+		if err1 != nil {
+			d.rdb.Del(ctx, keys[1])
+			return false, err1
+		}
+
+		if len(gmList) == 0 {
+			break
+		}
+
+		args := make([]interface{}, 0, len(gmList)*2+2)
+		args = append(args, token, expire)
+		for _, gm := range gmList {
+			args = append(args, gm.ID, gm.UID.String())
+		}
+
+		held, err1 := buildGroupMemberUIDSetScript.Run(ctx, d.rdb, keys, args...).Int()
+		if err1 != nil {
+			d.rdb.Del(ctx, keys[1])
+			return false, err1
+		}
+
+		// lock expired or cache purged, give up.
+		if held == 0 {
+			return false, nil
+		}
+
+		afterID = gmList[len(gmList)-1].ID
+		if len(gmList) < groupMemberUIDSetLoadBatch {
+			break
+		}
+	}
+
+	result, err := finishGroupMemberUIDSetScript.Run(ctx, d.rdb, keys, token, data.GroupMembersCacheExpire).Int()
+	if err != nil {
+		d.rdb.Del(ctx, keys[1])
+		return false, err
+	}
+
+	return result == 1, nil
+}
+
+// AddToGroupMemberUIDSet adds members to member uid set if the set is cached or being built,
+// members without id are skipped.
+func (d *GroupMemberDao) AddToGroupMemberUIDSet(ctx context.Context, gid types.ID, members ...*data.GroupMember) error {
+	args := make([]interface{}, 0, len(members)*2)
+	for _, gm := range members {
+		if gm.ID == 0 {
+			continue
+		}
+
+		args = append(args, gm.ID, gm.UID.String())
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	return addToGroupMemberUIDSetScript.Run(ctx, d.rdb, groupMemberUIDSetKeys(gid), args...).Err()
+}
+
+// RemoveFromGroupMemberUIDSet removes uids from member uid set and the set being built.
+func (d *GroupMemberDao) RemoveFromGroupMemberUIDSet(ctx context.Context, gid types.ID, uids ...types.ID) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(uids)+1)
+	args = append(args, int(groupMemberUIDSetBuildTimeout.Seconds()))
+	for _, uid := range uids {
+		args = append(args, uid.String())
+	}
+
+	return removeFromGroupMemberUIDSetScript.Run(ctx, d.rdb, groupMemberUIDSetKeys(gid), args...).Err()
+}
+
+// ListGroupMemberUIDsFromSet list member uids from member uid set by cursor,
+// afterID is the id of last member of previous page. Ids of members are returned along with uids.
+// Should be called after LoadGroupMemberUIDSet returns true.
+func (d *GroupMemberDao) ListGroupMemberUIDsFromSet(ctx context.Context, gid types.ID, afterID uint64, limit int) (
+	[]*data.GroupMember, error) {
+	zs, err := d.rdb.ZRangeByScoreWithScores(ctx, groupMemberUIDSetKey(gid), &redisv8.ZRangeBy{
+		Min:   fmt.Sprintf("(%d", afterID),
+		Max:   "+inf",
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	gmList := make([]*data.GroupMember, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		uid, err1 := strconv.ParseInt(member, 10, 64)
+		if err1 != nil {
+			continue
+		}
+
+		gmList = append(gmList, &data.GroupMember{ID: uint64(z.Score), GID: gid, UID: types.ID(uid)})
+	}
+
+	return gmList, nil
+}
+
+// ListInGroupUIDsFromSet returns uids in member uid set from given uid list.
+// Should be called after LoadGroupMemberUIDSet returns true.
+func (d *GroupMemberDao) ListInGroupUIDsFromSet(ctx context.Context, gid types.ID, uids []types.ID) ([]types.ID, error) {
+	result := make([]types.ID, 0, len(uids))
+	if len(uids) == 0 {
+		return result, nil
+	}
+
+	members := make([]string, len(uids))
+	for i, uid := range uids {
+		members[i] = uid.String()
+	}
+
+	scores, err := d.rdb.ZMScore(ctx, groupMemberUIDSetKey(gid), members...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, score := range scores {
+		// score is id of group member row which is never 0, 0 means not a member.
+		if score > 0 {
+			result = append(result, uids[i])
+		}
+	}
+
+	return result, nil
 }
 
 func (d *GroupMemberDao) GetGroupMemberByGIDUID(ctx context.Context, gid, uid types.ID) (*data.GroupMember, error) {
@@ -114,6 +518,60 @@ func (d *GroupMemberDao) ListGroupMembersByGID(ctx context.Context, gid types.ID
 	return groupMembers, nil
 }
 
+// CountGroupMembersByGIDs counts member rows of each group in given gid list,
+// groups without any member are absent from result.
+func (d *GroupMemberDao) CountGroupMembersByGIDs(ctx context.Context, gids []types.ID) (map[types.ID]int, error) {
+	result := make(map[types.ID]int, len(gids))
+	if len(gids) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		GID   types.ID `gorm:"column:gid"`
+		Count int      `gorm:"column:count"`
+	}
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Select("gid, COUNT(*) AS count").
+		Where("gid IN (?)", gids).Group("gid").Scan(&rows)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	for _, row := range rows {
+		result[row.GID] = row.Count
+	}
+
+	return result, nil
+}
+
+// ListGroupMemberUIDsAfter list id and uid of members whose id greater than afterID, ordered by id.
+func (d *GroupMemberDao) ListGroupMemberUIDsAfter(ctx context.Context, gid types.ID, afterID uint64, limit int) (
+	[]*data.GroupMember, error) {
+	groupMembers := make([]*data.GroupMember, 0)
+	tx := db.GetDBFromCtx(ctx).Select("id, uid").Where("gid = ? AND id > ?", gid, afterID).
+		Order("id").Limit(limit).Find(&groupMembers)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groupMembers, nil
+}
+
+// ListGroupMembersByUIDs list members of group in given uid list.
+func (d *GroupMemberDao) ListGroupMembersByUIDs(ctx context.Context, gid types.ID, uids []types.ID) (
+	[]*data.GroupMember, error) {
+	groupMembers := make([]*data.GroupMember, 0)
+	if len(uids) == 0 {
+		return groupMembers, nil
+	}
+
+	tx := db.GetDBFromCtx(ctx).Where("gid = ? AND uid IN (?)", gid, uids).Find(&groupMembers)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groupMembers, nil
+}
+
 func (d *GroupMemberDao) ListGroupByUID(ctx context.Context, uid types.ID) ([]*data.GroupMember, error) {
 	groupMembers := make([]*data.GroupMember, 0)
 	tx := db.GetDBFromCtx(ctx).Where("uid = ?", uid).Find(&groupMembers)
@@ -162,3 +620,200 @@ func (d *GroupMemberDao) DeleteGroupMembers(ctx context.Context, gid types.ID, u
 
 	return nil
 }
+
+// CountGroupMembersByType counts members of group with given member type.
+func (d *GroupMemberDao) CountGroupMembersByType(ctx context.Context, gid types.ID, typ grouppb.GroupMember_Type) (
+	int64, error) {
+	var count int64
+	err := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Where("gid = ? AND type = ?", gid, typ).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// UpdateGroupMembersType updates member type of given uid list in group.
+func (d *GroupMemberDao) UpdateGroupMembersType(ctx context.Context, gid types.ID, uids []types.ID,
+	typ grouppb.GroupMember_Type) error {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Where("gid = ? AND uid in (?)", gid, uids).
+		Updates(map[string]interface{}{
+			"type":       typ,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// UpdateGroupMemberMute updates status and unmute time of member.
+func (d *GroupMemberDao) UpdateGroupMemberMute(ctx context.Context, gid, uid types.ID,
+	status grouppb.GroupMember_Status, muteUntil int64) error {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Where("gid = ? AND uid = ?", gid, uid).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"mute_until": muteUntil,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListExpiredMutedMembers list silent members whose mute expired at given time.
+func (d *GroupMemberDao) ListExpiredMutedMembers(ctx context.Context, now int64, limit int) (
+	[]*data.GroupMember, error) {
+	groupMembers := make([]*data.GroupMember, 0)
+	tx := db.GetDBFromCtx(ctx).Where("status = ? AND mute_until > 0 AND mute_until <= ?",
+		grouppb.GroupMember_StatusSilent, now).Limit(limit).Find(&groupMembers)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groupMembers, nil
+}
+
+// UnmuteExpiredGroupMember unmutes member if mute expired at given time.
+// It returns false if member is not muted or muted again with a new time.
+func (d *GroupMemberDao) UnmuteExpiredGroupMember(ctx context.Context, gid, uid types.ID, now int64) (bool, error) {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).
+		Where("gid = ? AND uid = ? AND status = ? AND mute_until > 0 AND mute_until <= ?",
+			gid, uid, grouppb.GroupMember_StatusSilent, now).
+		Updates(map[string]interface{}{
+			"status":     grouppb.GroupMember_StatusActive,
+			"mute_until": 0,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+func (d *GroupMemberDao) UpdateGroupMemberNickname(ctx context.Context, gid, uid types.ID, nickname string) error {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Where("gid = ? AND uid = ?", gid, uid).
+		Updates(map[string]interface{}{
+			"nickname":   nickname,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (d *GroupMemberDao) UpdateGroupMemberTitle(ctx context.Context, gid, uid types.ID, title string) error {
+	tx := db.GetDBFromCtx(ctx).Model(&data.GroupMember{}).Where("gid = ? AND uid = ?", gid, uid).
+		Updates(map[string]interface{}{
+			"title":      title,
+			"updated_at": time.Now().Unix(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// groupMemberRoleOrderExpr is sql expression of data.GroupMember.RoleOrder.
+var groupMemberRoleOrderExpr = fmt.Sprintf("CASE type WHEN %d THEN 0 WHEN %d THEN 1 ELSE 2 END",
+	grouppb.GroupMember_TypeOwner, grouppb.GroupMember_TypeAdmin)
+
+// ListGroupMembersOption is the filter and cursor of listing group members.
+type ListGroupMembersOption struct {
+	Types    []grouppb.GroupMember_Type
+	Statuses []grouppb.GroupMember_Status
+	// OrderByRole sorts members by role then join time, otherwise by join time only.
+	OrderByRole bool
+	// AfterRoleOrder and AfterID are the position of the last member of previous page,
+	// AfterRoleOrder is only used when ordering by role.
+	AfterRoleOrder int
+	AfterID        uint64
+	Limit          int
+}
+
+// ListGroupMembers list members of group by cursor, members are paged by keyset instead of offset,
+// so that listing large groups won't be slower page by page.
+func (d *GroupMemberDao) ListGroupMembers(ctx context.Context, gid types.ID, opt *ListGroupMembersOption) (
+	[]*data.GroupMember, error) {
+	groupMembers := make([]*data.GroupMember, 0)
+	tx := db.GetDBFromCtx(ctx).Where("gid = ?", gid)
+	if len(opt.Types) > 0 {
+		tx = tx.Where("type IN (?)", opt.Types)
+	}
+
+	if len(opt.Statuses) > 0 {
+		tx = tx.Where("status IN (?)", opt.Statuses)
+	}
+
+	if opt.OrderByRole {
+		tx = tx.Where(fmt.Sprintf("((%s > ?) OR (%s = ? AND id > ?))", groupMemberRoleOrderExpr, groupMemberRoleOrderExpr),
+			opt.AfterRoleOrder, opt.AfterRoleOrder, opt.AfterID).
+			Order(groupMemberRoleOrderExpr)
+	} else {
+		tx = tx.Where("id > ?", opt.AfterID)
+	}
+
+	tx = tx.Order("id").Limit(opt.Limit).Find(&groupMembers)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return groupMembers, nil
+}
+
+const groupMemberColumns = "id, gid, uid, type, status, inviter_uid, mute_until, nickname, title, created_at, updated_at"
+
+// ArchiveGroupMembers moves all members of group to group_member_archive table, count of members is returned.
+// Should be called in transaction.
+func (d *GroupMemberDao) ArchiveGroupMembers(ctx context.Context, gid types.ID) (int64, error) {
+	return d.moveGroupMembers(ctx, "group_member", "group_member_archive", gid)
+}
+
+// RestoreArchivedGroupMembers moves archived members of group back to group_member table,
+// count of members is returned. Should be called in transaction.
+func (d *GroupMemberDao) RestoreArchivedGroupMembers(ctx context.Context, gid types.ID) (int64, error) {
+	return d.moveGroupMembers(ctx, "group_member_archive", "group_member", gid)
+}
+
+func (d *GroupMemberDao) moveGroupMembers(ctx context.Context, from, to string, gid types.ID) (int64, error) {
+	tx := db.GetDBFromCtx(ctx).Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE gid = ?",
+		to, groupMemberColumns, groupMemberColumns, from), gid)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	count := tx.RowsAffected
+	tx = db.GetDBFromCtx(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE gid = ?", from), gid)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return count, nil
+}
+
+// DeleteArchivedGroupMembers deletes archived members of group permanently.
+func (d *GroupMemberDao) DeleteArchivedGroupMembers(ctx context.Context, gid types.ID) error {
+	return db.GetDBFromCtx(ctx).Exec("DELETE FROM group_member_archive WHERE gid = ?", gid).Error
+}
+
+// ListExpiredArchivedGIDs returns gid list of groups which have archived members and dissolved before given time.
+func (d *GroupMemberDao) ListExpiredArchivedGIDs(ctx context.Context, dissolvedBefore int64, limit int) (
+	[]types.ID, error) {
+	result := make([]types.ID, 0)
+	tx := db.GetDBFromCtx(ctx).Table("group_member_archive AS a").Distinct("a.gid").
+		Joins("JOIN `group` AS g ON g.gid = a.gid").
+		Where("g.status = ? AND g.dissolved_at < ?", grouppb.GroupStatus_Dissolved, dissolvedBefore).
+		Limit(limit).Find(&result)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return result, nil
+}
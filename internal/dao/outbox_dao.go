@@ -0,0 +1,133 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/go-goim/core/pkg/db"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+type OutboxDao struct{}
+
+var (
+	outboxDao     *OutboxDao
+	outboxDaoOnce sync.Once
+)
+
+func GetOutboxDao() *OutboxDao {
+	outboxDaoOnce.Do(func() {
+		outboxDao = &OutboxDao{}
+	})
+	return outboxDao
+}
+
+// CreateOutbox writes outbox rows, should be called in the transaction of business data.
+func (d *OutboxDao) CreateOutbox(ctx context.Context, outbox ...*data.Outbox) error {
+	now := time.Now().Unix()
+	for _, o := range outbox {
+		o.Status = data.OutboxStatusPending
+		o.CreatedAt = now
+		o.UpdatedAt = now
+	}
+
+	return db.GetDBFromCtx(ctx).CreateInBatches(outbox, len(outbox)).Error
+}
+
+// ClaimPendingOutbox claims pending rows which can be published at given time order by id.
+// Claimed rows are hidden from other relays for lease seconds by pushing next_retry_at forward,
+// rows locked by other relays are skipped, so concurrent relays never claim the same row.
+// If relay dies before marking rows delivered or failed, rows will be claimed again after lease.
+func (d *OutboxDao) ClaimPendingOutbox(ctx context.Context, now, lease int64, limit int) ([]*data.Outbox, error) {
+	list := make([]*data.Outbox, 0)
+	err := db.Transaction(ctx, func(ctx2 context.Context) error {
+		tx := db.GetDBFromCtx(ctx2).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_retry_at <= ?", data.OutboxStatusPending, now).
+			Order("id").Limit(limit).Find(&list)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		if len(list) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, len(list))
+		for i, o := range list {
+			ids[i] = o.ID
+		}
+
+		return db.GetDBFromCtx(ctx2).Model(&data.Outbox{}).Where("id IN (?)", ids).UpdateColumns(map[string]interface{}{
+			"next_retry_at": now + lease,
+			"updated_at":    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (d *OutboxDao) MarkDelivered(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	return db.GetDBFromCtx(ctx).Model(&data.Outbox{}).Where("id IN (?)", ids).UpdateColumns(map[string]interface{}{
+		"status":       data.OutboxStatusDelivered,
+		"delivered_at": now,
+		"updated_at":   now,
+	}).Error
+}
+
+// MarkFailed saves attempts, next retry time and error of outbox row.
+func (d *OutboxDao) MarkFailed(ctx context.Context, o *data.Outbox) error {
+	return db.GetDBFromCtx(ctx).Model(o).UpdateColumns(map[string]interface{}{
+		"attempts":      o.Attempts,
+		"next_retry_at": o.NextRetryAt,
+		"last_error":    o.LastError,
+		"updated_at":    time.Now().Unix(),
+	}).Error
+}
+
+// PurgeDeliveredOutbox deletes at most limit rows delivered before given unix time, returns count of deleted rows.
+func (d *OutboxDao) PurgeDeliveredOutbox(ctx context.Context, before int64, limit int) (int64, error) {
+	tx := db.GetDBFromCtx(ctx).Exec("DELETE FROM outbox WHERE status = ? AND delivered_at < ? LIMIT ?",
+		data.OutboxStatusDelivered, before, limit)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return tx.RowsAffected, nil
+}
+
+// GetOldestPendingOutbox returns the earliest created pending row, nil if there is no pending row.
+func (d *OutboxDao) GetOldestPendingOutbox(ctx context.Context) (*data.Outbox, error) {
+	o := &data.Outbox{}
+	tx := db.GetDBFromCtx(ctx).Where("status = ?", data.OutboxStatusPending).Order("id").First(o)
+	if tx.Error != nil {
+		if tx.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	return o, nil
+}
+
+func (d *OutboxDao) CountPendingOutbox(ctx context.Context) (int64, error) {
+	var count int64
+	err := db.GetDBFromCtx(ctx).Model(&data.Outbox{}).Where("status = ?", data.OutboxStatusPending).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
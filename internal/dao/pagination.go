@@ -0,0 +1,23 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// Paginate returns a gorm scope which limits query result by given page and page size.
+// page starts from 1, page size will be set to default value if it is out of range.
+func Paginate(page, pageSize int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if page <= 0 {
+			page = 1
+		}
+
+		if pageSize <= 0 || pageSize > data.MaxPageSize {
+			pageSize = data.DefaultPageSize
+		}
+
+		return db.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+}
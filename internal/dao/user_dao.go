@@ -56,11 +56,14 @@ func (u *UserDao) GetUser(ctx context.Context, id uint64) (*data.User, error) {
 	return user, nil
 }
 
+func userCacheKey(uid types.ID) string {
+	return fmt.Sprintf("user:%d", uid.Int64())
+}
+
 func (u *UserDao) getUserFromCache(ctx context.Context, uid types.ID) (*data.User, error) {
 	log.Debug("getUserFromCache", "uid", uid)
 	user := &data.User{}
-	key := fmt.Sprintf("user:%s", uid)
-	val, err := cache.Get(ctx, key) // use default cache
+	val, err := cache.Get(ctx, userCacheKey(uid)) // use default cache
 	if err != nil {
 		if err == cache.ErrCacheMiss {
 			return nil, nil
@@ -79,13 +82,12 @@ func (u *UserDao) getUserFromCache(ctx context.Context, uid types.ID) (*data.Use
 
 func (u *UserDao) setUserToCache(ctx context.Context, user *data.User) error {
 	log.Debug("setUserToCache", "uid", user.ID)
-	key := fmt.Sprintf("user:%d", user.UID.Int64())
 	val, err := json.Marshal(user)
 	if err != nil {
 		return err
 	}
 
-	return cache.Set(ctx, key, val, time.Duration(data.UserCacheExpire+util.RandIntn(data.UserCacheExpire/10))*time.Second)
+	return cache.Set(ctx, userCacheKey(user.UID), val, time.Duration(data.UserCacheExpire+util.RandIntn(data.UserCacheExpire/10))*time.Second)
 
 }
 
@@ -213,3 +215,43 @@ func (u *UserDao) ListUsers(ctx context.Context, uids ...types.ID) ([]*data.User
 
 	return users, nil
 }
+
+// ListUsersWithCache list users of given uids, users are loaded from cache first and missed ones from db.
+// Users loaded from db are put to cache, error of cache is only logged.
+func (u *UserDao) ListUsersWithCache(ctx context.Context, uids ...types.ID) ([]*data.User, error) {
+	var (
+		users  = make([]*data.User, 0, len(uids))
+		missed = make([]types.ID, 0)
+	)
+
+	for _, uid := range uids {
+		user, err := u.getUserFromCache(ctx, uid)
+		if err != nil {
+			log.Error("get user from cache error", "uid", uid, "err", err)
+		}
+
+		if user == nil {
+			missed = append(missed, uid)
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	if len(missed) == 0 {
+		return users, nil
+	}
+
+	dbUsers, err := u.ListUsers(ctx, missed...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range dbUsers {
+		if err = u.setUserToCache(ctx, user); err != nil {
+			log.Error("set user to cache error", "uid", user.UID, "err", err)
+		}
+	}
+
+	return append(users, dbUsers...), nil
+}
@@ -26,7 +26,8 @@ func (Friend) TableName() string {
 }
 
 const (
-	UserMaxFriendCount = 2000 // UserMaxRelationCount is the max count of user relation.
+	UserMaxFriendCount   = 2000    // UserMaxRelationCount is the max count of user relation.
+	FriendSetCacheExpire = 60 * 60 // 1 hour
 )
 
 func (ur *Friend) IsFriend() bool {
@@ -0,0 +1,65 @@
+package data
+
+import (
+	friendpb "github.com/go-goim/api/user/friend/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// FriendRecommendation is the model of friend_recommendation table based on gorm,
+// which stores precomputed "people you may know" suggestions of a user.
+// FriendRecommendation data stored in mysql.
+type FriendRecommendation struct {
+	ID  uint64   `gorm:"primary_key"`
+	UID types.ID `gorm:"column:uid"`
+	// TargetUID is the uid of the recommended user.
+	TargetUID types.ID `gorm:"column:target_uid"`
+	// Score is the rank of the recommendation, higher is better.
+	Score int `gorm:"column:score"`
+	// MutualFriendCount is the count of friends shared by UID and TargetUID.
+	MutualFriendCount int `gorm:"column:mutual_friend_count"`
+	// SharedGroupCount is the count of groups both UID and TargetUID are in.
+	SharedGroupCount int `gorm:"column:shared_group_count"`
+	// Viewed is true if UID viewed TargetUID recently.
+	Viewed bool `gorm:"column:viewed"`
+	// Dismissed is true if UID dismissed the recommendation, it won't be recommended again.
+	Dismissed bool  `gorm:"column:dismissed"`
+	CreatedAt int64 `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt int64 `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (FriendRecommendation) TableName() string {
+	return "friend_recommendation"
+}
+
+const (
+	RecommendMutualFriendWeight = 10 // score of each mutual friend
+	RecommendSharedGroupWeight  = 5  // score of each shared group
+	RecommendViewedWeight       = 20 // score if user viewed the target recently
+	// RecommendMaxGroupSize is the max member count of group used to find recommendations,
+	// members of large groups are barely acquaintances.
+	RecommendMaxGroupSize = 500
+	// RecommendMaxCount is the max count of recommendations stored per user.
+	RecommendMaxCount = 50
+	// ProfileViewExpire is how long a profile view counts as recent.
+	ProfileViewExpire = 60 * 60 * 24 * 7 // 7 days
+	// ActiveUserExpire is how long a user counts as active after last activity,
+	// recommendations of inactive users are not refreshed.
+	ActiveUserExpire = 60 * 60 * 24 * 7 // 7 days
+)
+
+func (r *FriendRecommendation) CalculateScore() {
+	r.Score = r.MutualFriendCount*RecommendMutualFriendWeight + r.SharedGroupCount*RecommendSharedGroupWeight
+	if r.Viewed {
+		r.Score += RecommendViewedWeight
+	}
+}
+
+func (r *FriendRecommendation) ToProto() *friendpb.FriendRecommendation {
+	return &friendpb.FriendRecommendation{
+		Uid:               r.TargetUID.Int64(),
+		Score:             int32(r.Score),
+		MutualFriendCount: int32(r.MutualFriendCount),
+		SharedGroupCount:  int32(r.SharedGroupCount),
+		Viewed:            r.Viewed,
+	}
+}
@@ -20,6 +20,11 @@ func (FriendRequest) TableName() string {
 	return "friend_request"
 }
 
+const (
+	MaxBatchConfirmCount  = 500 // MaxBatchConfirmCount is the max count of friend requests confirmed in one call.
+	BatchConfirmChunkSize = 50  // BatchConfirmChunkSize is the count of friend requests confirmed in one transaction.
+)
+
 func (fr *FriendRequest) IsRequested() bool {
 	return fr.Status == friendpb.FriendRequestStatus_REQUESTED
 }
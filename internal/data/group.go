@@ -1,6 +1,9 @@
 package data
 
 import (
+	"strings"
+	"unicode/utf8"
+
 	grouppb "github.com/go-goim/api/user/group/v1"
 	"github.com/go-goim/core/pkg/types"
 )
@@ -8,35 +11,148 @@ import (
 // Group is the model of group table based on gorm, which contains group basic info.
 // Group data stored in mysql.
 type Group struct {
-	ID          uint64              `gorm:"primary_key"`
-	GID         types.ID            `gorm:"column:gid"`
-	Name        string              `gorm:"column:name"`
-	Description string              `gorm:"column:description"`
-	Avatar      string              `gorm:"column:avatar"`
-	MaxMembers  int                 `gorm:"column:max_members"`
-	MemberCount int                 `gorm:"column:member_count"`
-	Status      grouppb.GroupStatus `gorm:"column:status"`
-	OwnerUID    types.ID            `gorm:"column:owner_uid"`
-	CreatedAt   int64               `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt   int64               `gorm:"column:updated_at;autoUpdateTime"`
+	ID           uint64                  `gorm:"primary_key"`
+	GID          types.ID                `gorm:"column:gid"`
+	Name         string                  `gorm:"column:name"`
+	Description  string                  `gorm:"column:description"`
+	Avatar       string                  `gorm:"column:avatar"`
+	Tier         grouppb.GroupTier       `gorm:"column:tier"`
+	MaxMembers   int                     `gorm:"column:max_members"`
+	MemberCount  int                     `gorm:"column:member_count"`
+	MaxAdmins    int                     `gorm:"column:max_admins"`
+	JoinPolicy   grouppb.GroupJoinPolicy `gorm:"column:join_policy"`
+	JoinQuestion string                  `gorm:"column:join_question"`
+	JoinAnswer   string                  `gorm:"column:join_answer"`
+	Public       bool                    `gorm:"column:public"`
+	Tags         string                  `gorm:"column:tags"` // comma separated tags with leading and trailing comma
+	Status       grouppb.GroupStatus     `gorm:"column:status"`
+	MuteUntil    int64                   `gorm:"column:mute_until"` // 0 means muted indefinitely if status is silent
+	DissolvedAt  int64                   `gorm:"column:dissolved_at"`
+	ActiveAt     int64                   `gorm:"column:active_at"` // last time message sent to group
+	OwnerUID     types.ID                `gorm:"column:owner_uid"`
+	CreatedAt    int64                   `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    int64                   `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (Group) TableName() string {
 	return "group"
 }
 
+const (
+	MaxGroupTagCount  = 5
+	MaxGroupTagLength = 16 // max length of tag in runes
+	// GroupActiveAtUpdateInterval is the min interval in seconds of updating ActiveAt,
+	// so that sending messages won't write db every time.
+	GroupActiveAtUpdateInterval = 60 * 5
+	// GroupCacheExpire is the expire time in seconds of group cached for checking send ability.
+	GroupCacheExpire = 60
+)
+
+// NormalizeGroupTags trims, lowercases and dedupes tags, false is returned if any tag is invalid.
+func NormalizeGroupTags(tags []string) ([]string, bool) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || strings.Contains(tag, ",") || utf8.RuneCountInString(tag) > MaxGroupTagLength {
+			return nil, false
+		}
+
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+
+	if len(result) > MaxGroupTagCount {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// SetTags sets normalized tags to group, tags are wrapped with commas so that a tag can be matched by ",tag,".
+func (g *Group) SetTags(tags []string) {
+	if len(tags) == 0 {
+		g.Tags = ""
+		return
+	}
+
+	g.Tags = "," + strings.Join(tags, ",") + ","
+}
+
+func (g *Group) TagList() []string {
+	tags := strings.Trim(g.Tags, ",")
+	if tags == "" {
+		return nil
+	}
+
+	return strings.Split(tags, ",")
+}
+
 func (g *Group) IsSilent() bool {
 	return g.Status == grouppb.GroupStatus_Silent
 }
 
+// IsSuperGroup checks whether group is in super tier, member uids of super group are kept in cache
+// and its members should never be loaded at once.
+func (g *Group) IsSuperGroup() bool {
+	return g.Tier == grouppb.GroupTier_Super
+}
+
+func (g *Group) IsDissolved() bool {
+	return g.Status == grouppb.GroupStatus_Dissolved
+}
+
+// RemainingCapacity returns how many members can still join the group.
+func (g *Group) RemainingCapacity() int {
+	if g.MemberCount >= g.MaxMembers {
+		return 0
+	}
+
+	return g.MaxMembers - g.MemberCount
+}
+
+// CanRestore checks whether dissolved group can still be restored at given time.
+func (g *Group) CanRestore(now int64, window int64) bool {
+	return g.IsDissolved() && g.DissolvedAt+window > now
+}
+
+// IsMuted checks whether group is muted at given time, group muted with expired time is not muted.
+func (g *Group) IsMuted(now int64) bool {
+	return g.IsSilent() && (g.MuteUntil == 0 || g.MuteUntil > now)
+}
+
+// CheckJoinAnswer checks answer of JoinQuestion, case and surrounding spaces are ignored.
+func (g *Group) CheckJoinAnswer(answer string) bool {
+	return strings.EqualFold(strings.TrimSpace(g.JoinAnswer), strings.TrimSpace(answer))
+}
+
+// ValidJoinPolicy checks JoinPolicy is known, and JoinQuestion and JoinAnswer are set if answer is required.
+func (g *Group) ValidJoinPolicy() bool {
+	if _, ok := grouppb.GroupJoinPolicy_name[int32(g.JoinPolicy)]; !ok {
+		return false
+	}
+
+	return g.JoinPolicy != grouppb.GroupJoinPolicy_AnswerQuestion || (g.JoinQuestion != "" && g.JoinAnswer != "")
+}
+
 func (g *Group) ToProto() *grouppb.Group {
 	return &grouppb.Group{
-		Gid:         g.GID.Int64(),
-		Name:        g.Name,
-		Description: g.Description,
-		Avatar:      g.Avatar,
-		MaxMembers:  int32(g.MaxMembers),
-		MemberCount: int32(g.MemberCount),
-		Status:      g.Status,
+		Gid:          g.GID.Int64(),
+		Name:         g.Name,
+		Description:  g.Description,
+		Avatar:       g.Avatar,
+		Tier:         g.Tier,
+		MaxMembers:   int32(g.MaxMembers),
+		MemberCount:  int32(g.MemberCount),
+		Status:       g.Status,
+		MuteUntil:    g.MuteUntil,
+		JoinPolicy:   g.JoinPolicy,
+		JoinQuestion: g.JoinQuestion,
+		Public:       g.Public,
+		Tags:         g.TagList(),
 	}
 }
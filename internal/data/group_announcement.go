@@ -0,0 +1,64 @@
+package data
+
+import (
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// GroupAnnouncement is the model of group_announcement table based on gorm,
+// which contains announcements published by group owner or admins, all history is kept.
+// GroupAnnouncement data stored in mysql.
+type GroupAnnouncement struct {
+	ID        uint64   `gorm:"primary_key"`
+	GID       types.ID `gorm:"column:gid"`
+	AuthorUID types.ID `gorm:"column:author_uid"`
+	Title     string   `gorm:"column:title"`
+	Content   string   `gorm:"column:content"`
+	Pinned    bool     `gorm:"column:pinned"`
+	ReadCount int      `gorm:"column:read_count"`
+	CreatedAt int64    `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt int64    `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (GroupAnnouncement) TableName() string {
+	return "group_announcement"
+}
+
+// GroupAnnouncementRead is the model of group_announcement_read table based on gorm,
+// which records members who have read an announcement.
+// GroupAnnouncementRead data stored in mysql.
+type GroupAnnouncementRead struct {
+	ID             uint64   `gorm:"primary_key"`
+	AnnouncementID uint64   `gorm:"column:announcement_id"`
+	UID            types.ID `gorm:"column:uid"`
+	CreatedAt      int64    `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (GroupAnnouncementRead) TableName() string {
+	return "group_announcement_read"
+}
+
+const (
+	MaxGroupAnnouncementTitleLength   = 64   // max length of announcement title in runes
+	MaxGroupAnnouncementContentLength = 4096 // max length of announcement content in runes
+)
+
+// ToProto converts announcement to proto, read count is only filled if withReadCount is true.
+func (a *GroupAnnouncement) ToProto(withReadCount bool) *grouppb.GroupAnnouncement {
+	pb := &grouppb.GroupAnnouncement{
+		Id:        a.ID,
+		Gid:       a.GID.Int64(),
+		AuthorUid: a.AuthorUID.Int64(),
+		Title:     a.Title,
+		Content:   a.Content,
+		Pinned:    a.Pinned,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+
+	if withReadCount {
+		pb.ReadCount = int32(a.ReadCount)
+	}
+
+	return pb
+}
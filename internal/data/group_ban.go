@@ -0,0 +1,43 @@
+package data
+
+import (
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// GroupBan is the model of group_ban table based on gorm,
+// banned users can not be added to or join the group until ban expired or removed.
+// GroupBan data stored in mysql.
+type GroupBan struct {
+	ID          uint64   `gorm:"primary_key"`
+	GID         types.ID `gorm:"column:gid"`
+	UID         types.ID `gorm:"column:uid"`
+	OperatorUID types.ID `gorm:"column:operator_uid"`
+	Reason      string   `gorm:"column:reason"`
+	ExpireAt    int64    `gorm:"column:expire_at"` // 0 means banned permanently
+	CreatedAt   int64    `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   int64    `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (GroupBan) TableName() string {
+	return "group_ban"
+}
+
+const (
+	MaxGroupBanReasonLength = 255
+)
+
+func (b *GroupBan) IsActive(now int64) bool {
+	return b.ExpireAt == 0 || b.ExpireAt > now
+}
+
+func (b *GroupBan) ToProto() *grouppb.GroupBan {
+	return &grouppb.GroupBan{
+		Gid:         b.GID.Int64(),
+		Uid:         b.UID.Int64(),
+		OperatorUid: b.OperatorUID.Int64(),
+		Reason:      b.Reason,
+		ExpireAt:    b.ExpireAt,
+		CreatedAt:   b.CreatedAt,
+	}
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// GroupInvite is the model of group_invite table based on gorm,
+// which contains shareable invite token created by group owner or admins.
+// GroupInvite data stored in mysql.
+type GroupInvite struct {
+	ID         uint64   `gorm:"primary_key"`
+	GID        types.ID `gorm:"column:gid"`
+	Token      string   `gorm:"column:token"`
+	InviterUID types.ID `gorm:"column:inviter_uid"`
+	MaxUses    int      `gorm:"column:max_uses"` // 0 means unlimited
+	UsedCount  int      `gorm:"column:used_count"`
+	ExpireAt   int64    `gorm:"column:expire_at"` // 0 means never expire
+	Revoked    bool     `gorm:"column:revoked"`
+	CreatedAt  int64    `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt  int64    `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (GroupInvite) TableName() string {
+	return "group_invite"
+}
+
+const (
+	GroupInviteTokenLength = 16 // GroupInviteTokenLength is the count of random bytes in invite token.
+)
+
+func (i *GroupInvite) IsExpired(now int64) bool {
+	return i.ExpireAt > 0 && i.ExpireAt <= now
+}
+
+func (i *GroupInvite) IsUsedUp() bool {
+	return i.MaxUses > 0 && i.UsedCount >= i.MaxUses
+}
+
+// IsUsable checks whether invite can still be used to join group.
+func (i *GroupInvite) IsUsable(now int64) bool {
+	return !i.Revoked && !i.IsExpired(now) && !i.IsUsedUp()
+}
+
+func (i *GroupInvite) ToProto() *grouppb.GroupInvite {
+	return &grouppb.GroupInvite{
+		Gid:        i.GID.Int64(),
+		Token:      i.Token,
+		InviterUid: i.InviterUID.Int64(),
+		MaxUses:    int32(i.MaxUses),
+		UsedCount:  int32(i.UsedCount),
+		ExpireAt:   i.ExpireAt,
+		Revoked:    i.Revoked,
+		CreatedAt:  i.CreatedAt,
+	}
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestGroupInvite_IsUsable(t *testing.T) {
+	const now = int64(1000)
+
+	tests := []struct {
+		name   string
+		invite GroupInvite
+		want   bool
+	}{
+		{"unlimited", GroupInvite{}, true},
+		{"not expired", GroupInvite{ExpireAt: now + 1}, true},
+		{"expired at now", GroupInvite{ExpireAt: now}, false},
+		{"expired", GroupInvite{ExpireAt: now - 1}, false},
+		{"uses left", GroupInvite{MaxUses: 2, UsedCount: 1}, true},
+		{"used up", GroupInvite{MaxUses: 2, UsedCount: 2}, false},
+		{"unlimited uses", GroupInvite{MaxUses: 0, UsedCount: 100}, true},
+		{"revoked", GroupInvite{Revoked: true}, false},
+		{"revoked but otherwise usable", GroupInvite{Revoked: true, MaxUses: 2, ExpireAt: now + 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.invite.IsUsable(now); got != tt.want {
+				t.Errorf("IsUsable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
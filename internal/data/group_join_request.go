@@ -0,0 +1,46 @@
+package data
+
+import (
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// GroupJoinRequest is the model of group_join_request table based on gorm,
+// which is created when user applies to join a group requires approval.
+// GroupJoinRequest data stored in mysql.
+type GroupJoinRequest struct {
+	ID          uint64                          `gorm:"primary_key"`
+	GID         types.ID                        `gorm:"column:gid"`
+	UID         types.ID                        `gorm:"column:uid"`
+	Message     string                          `gorm:"column:message"`
+	Status      grouppb.GroupJoinRequest_Status `gorm:"column:status"`
+	OperatorUID types.ID                        `gorm:"column:operator_uid"`
+	CreatedAt   int64                           `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   int64                           `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (GroupJoinRequest) TableName() string {
+	return "group_join_request"
+}
+
+const (
+	MaxGroupJoinRequestMessageLength = 255
+	MaxHandleGroupJoinRequestCount   = 100 // MaxHandleGroupJoinRequestCount is the max count of join requests handled in one call.
+)
+
+func (r *GroupJoinRequest) IsPending() bool {
+	return r.Status == grouppb.GroupJoinRequest_StatusPending
+}
+
+func (r *GroupJoinRequest) ToProto() *grouppb.GroupJoinRequest {
+	return &grouppb.GroupJoinRequest{
+		Id:          r.ID,
+		Gid:         r.GID.Int64(),
+		Uid:         r.UID.Int64(),
+		Message:     r.Message,
+		Status:      r.Status,
+		OperatorUid: r.OperatorUID.Int64(),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
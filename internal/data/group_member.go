@@ -8,13 +8,17 @@ import (
 // GroupMember is the model of group_member table based on gorm, which contains group member info.
 // GroupMember data stored in mysql.
 type GroupMember struct {
-	ID        uint64                     `gorm:"primary_key"`
-	GID       types.ID                   `gorm:"column:gid"`
-	UID       types.ID                   `gorm:"column:uid"`
-	Type      grouppb.GroupMember_Type   `gorm:"column:type"`
-	Status    grouppb.GroupMember_Status `gorm:"column:status"`
-	CreatedAt int64                      `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt int64                      `gorm:"column:updated_at;autoUpdateTime"`
+	ID         uint64                     `gorm:"primary_key"`
+	GID        types.ID                   `gorm:"column:gid"`
+	UID        types.ID                   `gorm:"column:uid"`
+	Type       grouppb.GroupMember_Type   `gorm:"column:type"`
+	Status     grouppb.GroupMember_Status `gorm:"column:status"`
+	InviterUID types.ID                   `gorm:"column:inviter_uid"` // 0 if member joined on their own
+	MuteUntil  int64                      `gorm:"column:mute_until"`  // 0 means muted indefinitely if status is silent
+	Nickname   string                     `gorm:"column:nickname"`
+	Title      string                     `gorm:"column:title"`
+	CreatedAt  int64                      `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt  int64                      `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 // TODO: maybe should use Group.ID instead of GID and User.ID instead of UID
@@ -23,11 +27,44 @@ func (GroupMember) TableName() string {
 	return "group_member"
 }
 
+const (
+	// GroupMemberStatusNotMember is the status cached for users not in group, it is never stored in db.
+	GroupMemberStatusNotMember = -1
+	GroupMembersCacheExpire    = 60 * 60 * 24 // 1 day
+	MaxGroupNicknameLength     = 32           // max length of nickname in runes
+	MaxGroupTitleLength        = 16           // max length of title in runes
+	// MaxExportGroupMemberUIDCount is max count of member uids exported per page.
+	MaxExportGroupMemberUIDCount = 5000
+)
+
+// IsMuted checks whether member is muted at given time, member muted with expired time is not muted.
+func (g *GroupMember) IsMuted(now int64) bool {
+	return g.Status == grouppb.GroupMember_StatusSilent && (g.MuteUntil == 0 || g.MuteUntil > now)
+}
+
+// RoleOrder returns position of member when members sorted by role, owner first, then admins and members.
+func (g *GroupMember) RoleOrder() int {
+	return groupMemberTypeRank[grouppb.GroupMember_TypeOwner] - groupMemberTypeRank[g.Type]
+}
+
+// DisplayName returns nickname in group, or given user name if nickname not set.
+func (g *GroupMember) DisplayName(userName string) string {
+	if g.Nickname != "" {
+		return g.Nickname
+	}
+
+	return userName
+}
+
 func (g *GroupMember) ToProto() *grouppb.GroupMember {
 	return &grouppb.GroupMember{
-		Gid:    g.GID.Int64(),
-		Uid:    g.UID.Int64(),
-		Type:   g.Type,
-		Status: g.Status,
+		Gid:        g.GID.Int64(),
+		Uid:        g.UID.Int64(),
+		Type:       g.Type,
+		Status:     g.Status,
+		InviterUid: g.InviterUID.Int64(),
+		MuteUntil:  g.MuteUntil,
+		Nickname:   g.Nickname,
+		Title:      g.Title,
 	}
 }
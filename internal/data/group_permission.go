@@ -0,0 +1,52 @@
+package data
+
+import (
+	grouppb "github.com/go-goim/api/user/group/v1"
+)
+
+// GroupPermission is the permission to manage group, permissions can be combined by bitwise or.
+type GroupPermission uint32
+
+const (
+	GroupPermissionEditInfo GroupPermission = 1 << iota
+	GroupPermissionAddMember
+	GroupPermissionRemoveMember
+	GroupPermissionMuteMember
+	GroupPermissionManageAdmin
+	GroupPermissionDissolve
+	GroupPermissionSetTitle
+)
+
+// groupPermissionMatrix defines permissions of each member type.
+var groupPermissionMatrix = map[grouppb.GroupMember_Type]GroupPermission{
+	grouppb.GroupMember_TypeOwner: GroupPermissionEditInfo | GroupPermissionAddMember | GroupPermissionRemoveMember |
+		GroupPermissionMuteMember | GroupPermissionManageAdmin | GroupPermissionDissolve | GroupPermissionSetTitle,
+	grouppb.GroupMember_TypeAdmin: GroupPermissionEditInfo | GroupPermissionAddMember | GroupPermissionRemoveMember |
+		GroupPermissionMuteMember | GroupPermissionSetTitle,
+	grouppb.GroupMember_TypeMember: 0,
+}
+
+// groupMemberTypeRank is the rank of member type, member can only manage members with lower rank.
+var groupMemberTypeRank = map[grouppb.GroupMember_Type]int{
+	grouppb.GroupMember_TypeOwner:  2,
+	grouppb.GroupMember_TypeAdmin:  1,
+	grouppb.GroupMember_TypeMember: 0,
+}
+
+// HasPermission checks whether member has given permission.
+func (g *GroupMember) HasPermission(perm GroupPermission) bool {
+	return groupPermissionMatrix[g.Type]&perm == perm
+}
+
+// CanManage checks whether member can manage target member, e.g. remove or mute target.
+func (g *GroupMember) CanManage(target *GroupMember) bool {
+	return groupMemberTypeRank[g.Type] > groupMemberTypeRank[target.Type]
+}
+
+func (g *GroupMember) IsOwner() bool {
+	return g.Type == grouppb.GroupMember_TypeOwner
+}
+
+func (g *GroupMember) IsAdmin() bool {
+	return g.Type == grouppb.GroupMember_TypeAdmin
+}
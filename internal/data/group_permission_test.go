@@ -0,0 +1,69 @@
+package data
+
+import (
+	"testing"
+
+	grouppb "github.com/go-goim/api/user/group/v1"
+)
+
+func TestGroupMember_HasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		memberType grouppb.GroupMember_Type
+		perm       GroupPermission
+		want       bool
+	}{
+		{"owner dissolve", grouppb.GroupMember_TypeOwner, GroupPermissionDissolve, true},
+		{"owner manage admin", grouppb.GroupMember_TypeOwner, GroupPermissionManageAdmin, true},
+		{"admin edit info", grouppb.GroupMember_TypeAdmin, GroupPermissionEditInfo, true},
+		{"admin mute member", grouppb.GroupMember_TypeAdmin, GroupPermissionMuteMember, true},
+		{"admin dissolve", grouppb.GroupMember_TypeAdmin, GroupPermissionDissolve, false},
+		{"admin manage admin", grouppb.GroupMember_TypeAdmin, GroupPermissionManageAdmin, false},
+		{"admin combined with missing one", grouppb.GroupMember_TypeAdmin,
+			GroupPermissionRemoveMember | GroupPermissionDissolve, false},
+		{"member edit info", grouppb.GroupMember_TypeMember, GroupPermissionEditInfo, false},
+		{"member add member", grouppb.GroupMember_TypeMember, GroupPermissionAddMember, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &GroupMember{Type: tt.memberType}
+			if got := m.HasPermission(tt.perm); got != tt.want {
+				t.Errorf("HasPermission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupMember_CanManage(t *testing.T) {
+	var (
+		owner  = grouppb.GroupMember_TypeOwner
+		admin  = grouppb.GroupMember_TypeAdmin
+		member = grouppb.GroupMember_TypeMember
+	)
+
+	tests := []struct {
+		name   string
+		actor  grouppb.GroupMember_Type
+		target grouppb.GroupMember_Type
+		want   bool
+	}{
+		{"owner manages admin", owner, admin, true},
+		{"owner manages member", owner, member, true},
+		{"owner manages owner", owner, owner, false},
+		{"admin manages member", admin, member, true},
+		{"admin manages admin", admin, admin, false},
+		{"admin manages owner", admin, owner, false},
+		{"member manages member", member, member, false},
+		{"member manages admin", member, admin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := &GroupMember{Type: tt.actor}
+			if got := actor.CanManage(&GroupMember{Type: tt.target}); got != tt.want {
+				t.Errorf("CanManage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
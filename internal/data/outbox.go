@@ -0,0 +1,62 @@
+package data
+
+// Outbox is the model of outbox table based on gorm, which stores events to be published to mq.
+// Outbox rows are written in the same transaction with business data,
+// and published by relay afterwards, so that events won't be lost if process dies after commit.
+type Outbox struct {
+	ID uint64 `gorm:"primary_key"`
+	// EventID is the stable id of event, also used as mq message key.
+	EventID   string `gorm:"column:event_id"`
+	Topic     string `gorm:"column:topic"`
+	EventType string `gorm:"column:event_type"`
+	Version   int32  `gorm:"column:version"`
+	// Payload is the marshaled protobuf message of event.
+	Payload []byte `gorm:"column:payload"`
+	Status  int    `gorm:"column:status"`
+	// Attempts is the count of failed publish attempts.
+	Attempts int `gorm:"column:attempts"`
+	// NextRetryAt is the unix time after which the row can be published again.
+	NextRetryAt int64  `gorm:"column:next_retry_at"`
+	LastError   string `gorm:"column:last_error"`
+	DeliveredAt int64  `gorm:"column:delivered_at"`
+	CreatedAt   int64  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   int64  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (Outbox) TableName() string {
+	return "outbox"
+}
+
+const (
+	OutboxStatusPending int = iota
+	OutboxStatusDelivered
+)
+
+const (
+	OutboxRetryBaseDelay = 1       // seconds
+	OutboxRetryMaxDelay  = 60 * 10 // 10 minutes
+	outboxMaxErrorLength = 255
+)
+
+func (o *Outbox) IsDelivered() bool {
+	return o.Status == OutboxStatusDelivered
+}
+
+// SetFailed increases attempts and calculates next retry time by exponential backoff.
+func (o *Outbox) SetFailed(now int64, err error) {
+	o.Attempts++
+	delay := int64(OutboxRetryBaseDelay)
+	for i := 1; i < o.Attempts && delay < OutboxRetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > OutboxRetryMaxDelay {
+		delay = OutboxRetryMaxDelay
+	}
+
+	o.NextRetryAt = now + delay
+	o.LastError = err.Error()
+	if len(o.LastError) > outboxMaxErrorLength {
+		o.LastError = o.LastError[:outboxMaxErrorLength]
+	}
+}
@@ -0,0 +1,6 @@
+package data
+
+const (
+	DefaultPageSize = 20  // DefaultPageSize is used when page size is not given or out of range.
+	MaxPageSize     = 100 // MaxPageSize is the max count of records can be queried in one page.
+)
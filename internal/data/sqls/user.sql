@@ -62,13 +62,25 @@ CREATE TABLE IF NOT EXISTS goim.group (
     `description` varchar(255) not null, -- group description
     `avatar` varchar(255) not null, -- group avatar
     `owner_uid` varchar(64) not null, -- 22 bytes of uuid
-    `max_members` int not null default 0, -- max members in group
+    `tier` tinyint not null default 0 COMMENT '0: standard; 1: large; 2: super',
+    `max_members` int not null default 0, -- max members in group, decided by tier
     `member_count` int not null default 0, -- current members in group
-    `status` tinyint not null default 0 COMMENT '0: normal; 1: silent;',
+    `max_admins` int not null default 0, -- max admins in group
+    `join_policy` tinyint not null default 0 COMMENT '0: approval required; 1: open; 2: invite only; 3: answer question',
+    `join_question` varchar(255) not null default '',
+    `join_answer` varchar(255) not null default '',
+    `public` tinyint not null default 0 COMMENT '1: can be found by search',
+    `tags` varchar(128) not null default '' COMMENT 'comma separated tags, e.g. ",game,music,"',
+    `status` tinyint not null default 0 COMMENT '0: normal; 1: silent; 2: dissolved',
+    `mute_until` int not null default 0 COMMENT 'unmute time if silent, 0: muted indefinitely',
+    `dissolved_at` int not null default 0,
+    `active_at` int not null default 0 COMMENT 'last time message sent to group',
     `created_at` int not null default 0,
     `updated_at` int not null default 0,
     primary key (`id`),
-    unique key (`gid`) COMMENT 'unique key for gid'
+    unique key (`gid`) COMMENT 'unique key for gid',
+    key (`owner_uid`, `tier`),
+    key (`public`, `status`, `member_count`)
 ) auto_increment = 10000 engine = innodb charset = utf8mb4;
 
 -- define group member table based on go structure GroupMember in current directory
@@ -78,10 +90,144 @@ CREATE TABLE IF NOT EXISTS goim.group_member (
     `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
     `gid` BIGINT not null,
     `uid` BIGINT not null,
-    `type` tinyint not null default 0 COMMENT '0: owner; 1: member',
+    `type` tinyint not null default 0 COMMENT '0: owner; 1: member; 2: admin',
     `status` tinyint not null default 0 COMMENT '0: normal; 1: silent;',
+    `inviter_uid` BIGINT not null default 0 COMMENT 'uid of who brought the member in',
+    `mute_until` int not null default 0 COMMENT 'unmute time if silent, 0: muted indefinitely',
+    `nickname` varchar(128) not null default '' COMMENT 'nickname in group',
+    `title` varchar(64) not null default '' COMMENT 'title assigned by owner or admins',
     `created_at` int not null default 0,
     `updated_at` int not null default 0,
     primary key (`id`),
     unique key (`gid`, `uid`) COMMENT 'unique key for gid and uid'
 ) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define group_member_archive table, members of dissolved groups are moved here until restore window passed
+DROP TABLE IF EXISTS goim.group_member_archive;
+
+CREATE TABLE IF NOT EXISTS goim.group_member_archive LIKE goim.group_member;
+
+-- define group_ban table based on go structure GroupBan in current directory
+DROP TABLE IF EXISTS goim.group_ban;
+
+CREATE TABLE IF NOT EXISTS goim.group_ban (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `gid` BIGINT not null,
+    `uid` BIGINT not null,
+    `operator_uid` BIGINT not null,
+    `reason` varchar(255) not null default '',
+    `expire_at` int not null default 0 COMMENT '0: banned permanently',
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    unique key (`gid`, `uid`) COMMENT 'unique key for gid and uid'
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define group_announcement table based on go structure GroupAnnouncement in current directory
+DROP TABLE IF EXISTS goim.group_announcement;
+
+CREATE TABLE IF NOT EXISTS goim.group_announcement (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `gid` BIGINT not null,
+    `author_uid` BIGINT not null,
+    `title` varchar(255) not null default '',
+    `content` text not null,
+    `pinned` tinyint not null default 0,
+    `read_count` int not null default 0 COMMENT 'count of members who have read',
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    key (`gid`, `pinned`, `id`)
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define group_announcement_read table based on go structure GroupAnnouncementRead in current directory
+DROP TABLE IF EXISTS goim.group_announcement_read;
+
+CREATE TABLE IF NOT EXISTS goim.group_announcement_read (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `announcement_id` BIGINT UNSIGNED not null,
+    `uid` BIGINT not null,
+    `created_at` int not null default 0,
+    primary key (`id`),
+    unique key (`announcement_id`, `uid`) COMMENT 'unique key for announcement_id and uid'
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define group_invite table based on go structure GroupInvite in current directory
+DROP TABLE IF EXISTS goim.group_invite;
+
+CREATE TABLE IF NOT EXISTS goim.group_invite (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `gid` BIGINT not null,
+    `token` varchar(64) not null,
+    `inviter_uid` BIGINT not null,
+    `max_uses` int not null default 0 COMMENT '0: unlimited',
+    `used_count` int not null default 0,
+    `expire_at` int not null default 0 COMMENT '0: never expire',
+    `revoked` tinyint not null default 0,
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    unique key (`token`),
+    key (`gid`)
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define group_join_request table based on go structure GroupJoinRequest in current directory
+DROP TABLE IF EXISTS goim.group_join_request;
+
+CREATE TABLE IF NOT EXISTS goim.group_join_request (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `gid` BIGINT not null,
+    `uid` BIGINT not null,
+    `message` varchar(255) not null default '',
+    `status` tinyint not null default 0 COMMENT '0: pending; 1: approved; 2: rejected; 3: withdrawn',
+    `operator_uid` BIGINT not null default 0 COMMENT 'uid of who approved or rejected',
+    `pending_uid` BIGINT as (IF(`status` = 0, `uid`, NULL)) stored COMMENT 'uid if pending, otherwise NULL',
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    unique key (`gid`, `pending_uid`) COMMENT 'at most one pending request of uid to group',
+    key (`gid`, `status`),
+    key (`uid`, `gid`)
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define friend_recommendation table based on go structure FriendRecommendation in current directory
+DROP TABLE IF EXISTS goim.friend_recommendation;
+
+CREATE TABLE IF NOT EXISTS goim.friend_recommendation (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `uid` BIGINT not null,
+    `target_uid` BIGINT not null,
+    `score` int not null default 0,
+    `mutual_friend_count` int not null default 0,
+    `shared_group_count` int not null default 0,
+    `viewed` tinyint not null default 0,
+    `dismissed` tinyint not null default 0 COMMENT '0: normal; 1: dismissed by uid',
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    unique key (`uid`, `target_uid`) COMMENT 'unique key for uid and target_uid',
+    key (`uid`, `dismissed`, `score`)
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
+
+-- define outbox table based on go structure Outbox in current directory
+DROP TABLE IF EXISTS goim.outbox;
+
+CREATE TABLE IF NOT EXISTS goim.outbox (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `event_id` varchar(32) not null,
+    `topic` varchar(64) not null,
+    `event_type` varchar(64) not null,
+    `version` int not null default 0,
+    `payload` blob not null,
+    `status` tinyint not null default 0 COMMENT '0: pending; 1: delivered',
+    `attempts` int not null default 0,
+    `next_retry_at` int not null default 0,
+    `last_error` varchar(255) not null default '',
+    `delivered_at` int not null default 0,
+    `created_at` int not null default 0,
+    `updated_at` int not null default 0,
+    primary key (`id`),
+    unique key (`event_id`) COMMENT 'unique key for event_id',
+    key (`status`, `next_retry_at`),
+    key (`status`, `delivered_at`)
+) auto_increment = 10000 engine = innodb charset = utf8mb4;
@@ -0,0 +1,30 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	eventv1 "github.com/go-goim/api/user/event/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// FriendEventVersion is the version of FriendEvent payload,
+// increase it when fields of payload change incompatibly.
+const FriendEventVersion = 1
+
+// NewFriendEvent creates friend event with a new event id.
+func NewFriendEvent(typ eventv1.FriendEventType, uid, friendUID types.ID) *eventv1.FriendEvent {
+	return &eventv1.FriendEvent{
+		EventId:   types.NewID().String(),
+		Version:   FriendEventVersion,
+		Type:      typ,
+		Uid:       uid.Int64(),
+		FriendUid: friendUID.Int64(),
+		CreatedAt: time.Now().Unix(),
+	}
+}
+
+// EnqueueFriendEvent writes relationship change event to outbox, it will be published to FriendEventTopic by relay.
+func (p *Publisher) EnqueueFriendEvent(ctx context.Context, e *eventv1.FriendEvent) error {
+	return p.Enqueue(ctx, FriendEventTopic, e.EventId, e.Type.String(), e.Version, e)
+}
@@ -0,0 +1,41 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	eventv1 "github.com/go-goim/api/user/event/v1"
+	"github.com/go-goim/core/pkg/types"
+)
+
+const (
+	// GroupEventTopic is the topic of group change events.
+	GroupEventTopic = "user_group_event_topic"
+	// GroupEventVersion is the version of GroupEvent payload,
+	// increase it when fields of payload change incompatibly.
+	GroupEventVersion = 1
+)
+
+// NewGroupEvent creates group event with a new event id.
+func NewGroupEvent(typ eventv1.GroupEventType, gid, operatorUID types.ID, uids ...types.ID) *eventv1.GroupEvent {
+	e := &eventv1.GroupEvent{
+		EventId:     types.NewID().String(),
+		Version:     GroupEventVersion,
+		Type:        typ,
+		Gid:         gid.Int64(),
+		OperatorUid: operatorUID.Int64(),
+		Uids:        make([]int64, len(uids)),
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	for i, uid := range uids {
+		e.Uids[i] = uid.Int64()
+	}
+
+	return e
+}
+
+// EnqueueGroupEvent writes group change event to outbox.
+func (p *Publisher) EnqueueGroupEvent(ctx context.Context, e *eventv1.GroupEvent) error {
+	return p.Enqueue(ctx, GroupEventTopic, e.EventId, e.Type.String(), e.Version, e)
+}
@@ -0,0 +1,107 @@
+package event
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/go-goim/user-service/internal/app"
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/data"
+)
+
+const (
+	// FriendEventTopic is the topic of relationship change events.
+	FriendEventTopic = "user_friend_event_topic"
+
+	// property keys of mq message, consumers can filter events without decoding payload.
+	PropertyEventID      = "event_id"
+	PropertyEventType    = "event_type"
+	PropertyEventVersion = "event_version"
+)
+
+// Producer is the subset of mq producer used to publish events.
+type Producer interface {
+	SendSync(ctx context.Context, msgs ...*primitive.Message) (*primitive.SendResult, error)
+}
+
+// Publisher publishes domain events to mq.
+// Events can be sent directly by Publish or written to outbox by Enqueue and sent by outbox relay later.
+type Publisher struct {
+	producer  Producer
+	outboxDao *dao.OutboxDao
+}
+
+var (
+	publisher     *Publisher
+	publisherOnce sync.Once
+)
+
+func GetPublisher() *Publisher {
+	publisherOnce.Do(func() {
+		publisher = NewPublisher(app.GetApplication().Producer, dao.GetOutboxDao())
+	})
+	return publisher
+}
+
+func NewPublisher(producer Producer, outboxDao *dao.OutboxDao) *Publisher {
+	return &Publisher{
+		producer:  producer,
+		outboxDao: outboxDao,
+	}
+}
+
+// Publish marshals payload and sends it to topic.
+// eventID is used as message key, so that consumers can deduplicate events.
+func (p *Publisher) Publish(ctx context.Context, topic, eventID, eventType string, version int32,
+	payload proto.Message) error {
+	body, err := proto.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return p.send(ctx, topic, eventID, eventType, version, body)
+}
+
+// Enqueue marshals payload and writes it to outbox.
+// It should be called with ctx of the transaction which changes business data.
+func (p *Publisher) Enqueue(ctx context.Context, topic, eventID, eventType string, version int32,
+	payload proto.Message) error {
+	body, err := proto.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return p.outboxDao.CreateOutbox(ctx, &data.Outbox{
+		EventID:   eventID,
+		Topic:     topic,
+		EventType: eventType,
+		Version:   version,
+		Payload:   body,
+	})
+}
+
+// PublishOutbox sends outbox row to its topic.
+func (p *Publisher) PublishOutbox(ctx context.Context, o *data.Outbox) error {
+	return p.send(ctx, o.Topic, o.EventID, o.EventType, o.Version, o.Payload)
+}
+
+func (p *Publisher) send(ctx context.Context, topic, eventID, eventType string, version int32, body []byte) error {
+	msg := primitive.NewMessage(topic, body)
+	msg.WithKeys([]string{eventID})
+	msg.WithProperties(map[string]string{
+		PropertyEventID:      eventID,
+		PropertyEventType:    eventType,
+		PropertyEventVersion: versionString(version),
+	})
+
+	_, err := p.producer.SendSync(ctx, msg)
+	return err
+}
+
+func versionString(version int32) string {
+	return strconv.FormatInt(int64(version), 10)
+}
@@ -0,0 +1,17 @@
+package event
+
+const (
+	// RetryEventTopic is the topic of side effects failed in rpc and retried asynchronously.
+	RetryEventTopic = "retry_event_topic"
+	// RetryEventDeadLetterTopic is the topic of retry events which cannot be handled after max retries.
+	RetryEventDeadLetterTopic = "retry_event_topic_dlq"
+
+	// RetryEventSetFriendStatusToCache is the event to set friend status cache of two users.
+	RetryEventSetFriendStatusToCache = "set_friend_status_to_cache"
+)
+
+// SetFriendStatusToCachePayload is the payload of RetryEventSetFriendStatusToCache.
+type SetFriendStatusToCachePayload struct {
+	UID       int64 `json:"uid"`
+	FriendUID int64 `json:"friend_uid"`
+}
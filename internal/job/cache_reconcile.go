@@ -0,0 +1,268 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	friendpb "github.com/go-goim/api/user/friend/v1"
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/metrics"
+)
+
+var (
+	cacheReconcileInterval  time.Duration
+	cacheReconcileBatchSize int64
+	cacheReconcileDryRun    bool
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&cacheReconcileInterval, "cache-reconcile-interval", time.Hour,
+		"interval of reconciling friend and group member cache with db")
+	cmd.GlobalFlagSet.Int64Var(&cacheReconcileBatchSize, "cache-reconcile-batch-size", 500,
+		"count of redis keys or hash fields scanned per batch when reconciling cache")
+	cmd.GlobalFlagSet.BoolVar(&cacheReconcileDryRun, "cache-reconcile-dry-run", false,
+		"only report differences between cache and db without repairing")
+}
+
+const (
+	cacheFriendStatus = "friend_status"
+	cacheGroupMembers = "group_members"
+
+	mismatchNotFriend      = "not_friend"
+	mismatchInvalidKey     = "invalid_key"
+	mismatchGroupNotExist  = "group_not_exist"
+	mismatchNotMember      = "not_member"
+	mismatchStatusMismatch = "status_mismatch"
+)
+
+// CacheReconcileJob scans friend_status:* keys and group_members_* hashes in batches,
+// compares them with friend and group_member tables and repairs mismatches.
+// In dry-run mode differences are only logged and counted.
+type CacheReconcileJob struct {
+	friendDao      *dao.FriendDao
+	groupDao       *dao.GroupDao
+	groupMemberDao *dao.GroupMemberDao
+	dryRun         bool
+}
+
+var _ Job = &CacheReconcileJob{}
+
+func NewCacheReconcileJob() *CacheReconcileJob {
+	return &CacheReconcileJob{
+		friendDao:      dao.GetUserRelationDao(),
+		groupDao:       dao.GetGroupDao(),
+		groupMemberDao: dao.GetGroupMemberDao(),
+		dryRun:         cacheReconcileDryRun,
+	}
+}
+
+func (j *CacheReconcileJob) Name() string {
+	return "cache_reconcile"
+}
+
+func (j *CacheReconcileJob) Interval() time.Duration {
+	return cacheReconcileInterval
+}
+
+func (j *CacheReconcileJob) Run(ctx context.Context) error {
+	if err := j.reconcileFriendStatus(ctx); err != nil {
+		return err
+	}
+
+	return j.reconcileGroupMembers(ctx)
+}
+
+func (j *CacheReconcileJob) reconcileFriendStatus(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := j.friendDao.ScanFriendStatusKeys(ctx, cursor, cacheReconcileBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err = j.reconcileFriendStatusKey(ctx, key); err != nil {
+				log.Error("reconcile friend status cache error", "key", key, "err", err)
+			}
+		}
+
+		if next == 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		cursor = next
+	}
+}
+
+// reconcileFriendStatusKey deletes the key if two users are not friends in both directions.
+// Missing keys are not checked, they are loaded lazily by FriendDao.CheckIsFriend.
+func (j *CacheReconcileJob) reconcileFriendStatusKey(ctx context.Context, key string) error {
+	uid, fuid, ok := dao.ParseFriendStatusKey(key)
+	if !ok {
+		j.report(cacheFriendStatus, mismatchInvalidKey, "key", key)
+		return nil
+	}
+
+	me, err := j.friendDao.GetFriendByStatus(ctx, uid, fuid, int(friendpb.FriendStatus_FRIEND))
+	if err != nil {
+		return err
+	}
+
+	friend, err := j.friendDao.GetFriendByStatus(ctx, fuid, uid, int(friendpb.FriendStatus_FRIEND))
+	if err != nil {
+		return err
+	}
+
+	if me != nil && friend != nil {
+		return nil
+	}
+
+	j.report(cacheFriendStatus, mismatchNotFriend, "key", key)
+	return j.repair(cacheFriendStatus, func() error {
+		return j.friendDao.DeleteFriendStatusFromCache(ctx, uid, fuid)
+	})
+}
+
+func (j *CacheReconcileJob) reconcileGroupMembers(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := j.groupMemberDao.ScanGroupMembersKeys(ctx, cursor, cacheReconcileBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err = j.reconcileGroupMembersKey(ctx, key); err != nil {
+				log.Error("reconcile group members cache error", "key", key, "err", err)
+			}
+		}
+
+		if next == 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		cursor = next
+	}
+}
+
+func (j *CacheReconcileJob) reconcileGroupMembersKey(ctx context.Context, key string) error {
+	gid, ok := dao.ParseGroupMembersKey(key)
+	if !ok {
+		j.report(cacheGroupMembers, mismatchInvalidKey, "key", key)
+		return nil
+	}
+
+	group, err := j.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		return err
+	}
+
+	if group == nil {
+		j.report(cacheGroupMembers, mismatchGroupNotExist, "gid", gid)
+		return j.repair(cacheGroupMembers, func() error {
+			return j.groupMemberDao.DeleteGroupMembersCache(ctx, gid)
+		})
+	}
+
+	var (
+		cursor, next uint64
+		statuses     map[types.ID]int
+		invalid      []string
+	)
+	for {
+		statuses, invalid, next, err = j.groupMemberDao.ScanMemberStatusFromCache(ctx, gid, cursor,
+			cacheReconcileBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if err = j.reconcileMemberStatuses(ctx, gid, statuses, invalid); err != nil {
+			return err
+		}
+
+		if next == 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		cursor = next
+	}
+}
+
+func (j *CacheReconcileJob) reconcileMemberStatuses(ctx context.Context, gid types.ID, statuses map[types.ID]int,
+	invalid []string) error {
+	for _, field := range invalid {
+		j.report(cacheGroupMembers, mismatchInvalidKey, "gid", gid, "field", field)
+	}
+
+	uids := make([]types.ID, 0, len(statuses))
+	for uid := range statuses {
+		uids = append(uids, uid)
+	}
+
+	members, err := j.groupMemberDao.ListGroupMembersByUIDs(ctx, gid, uids)
+	if err != nil {
+		return err
+	}
+
+	dbStatuses := make(map[types.ID]int, len(members))
+	for _, gm := range members {
+		dbStatuses[gm.UID] = int(gm.Status)
+	}
+
+	var staleFields = invalid
+	for uid, status := range statuses {
+		dbStatus, ok := dbStatuses[uid]
+		if !ok {
+			if status == data.GroupMemberStatusNotMember {
+				continue
+			}
+
+			j.report(cacheGroupMembers, mismatchNotMember, "gid", gid, "uid", uid)
+			staleFields = append(staleFields, uid.String())
+			continue
+		}
+
+		if dbStatus != status {
+			j.report(cacheGroupMembers, mismatchStatusMismatch, "gid", gid, "uid", uid,
+				"cache", status, "db", dbStatus)
+			if err = j.repair(cacheGroupMembers, func() error {
+				return j.groupMemberDao.SetMemberStatusToCache(ctx, gid, uid, dbStatus)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(staleFields) == 0 {
+		return nil
+	}
+
+	return j.repairN(cacheGroupMembers, len(staleFields), func() error {
+		return j.groupMemberDao.DeleteMemberStatusFromCache(ctx, gid, staleFields...)
+	})
+}
+
+func (j *CacheReconcileJob) report(cache, kind string, keyvals ...interface{}) {
+	metrics.CacheReconcileMismatch.WithLabelValues(cache, kind).Inc()
+	log.Info("cache mismatch found", append([]interface{}{"cache", cache, "kind", kind, "dry_run", j.dryRun},
+		keyvals...)...)
+}
+
+func (j *CacheReconcileJob) repair(cache string, fn func() error) error {
+	return j.repairN(cache, 1, fn)
+}
+
+func (j *CacheReconcileJob) repairN(cache string, n int, fn func() error) error {
+	if j.dryRun {
+		return nil
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	metrics.CacheReconcileRepaired.WithLabelValues(cache).Add(float64(n))
+	return nil
+}
@@ -0,0 +1,66 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/service"
+)
+
+var (
+	dissolvedGroupPurgeInterval  time.Duration
+	dissolvedGroupPurgeBatchSize int
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&dissolvedGroupPurgeInterval, "dissolved-group-purge-interval", time.Hour,
+		"interval of purging archived members of dissolved groups")
+	cmd.GlobalFlagSet.IntVar(&dissolvedGroupPurgeBatchSize, "dissolved-group-purge-batch-size", 100,
+		"count of dissolved groups purged per batch")
+}
+
+// DissolvedGroupPurgeJob deletes archived members of dissolved groups once their restore window passed.
+type DissolvedGroupPurgeJob struct {
+	groupService *service.GroupService
+}
+
+var _ Job = &DissolvedGroupPurgeJob{}
+
+func NewDissolvedGroupPurgeJob() *DissolvedGroupPurgeJob {
+	return &DissolvedGroupPurgeJob{
+		groupService: service.GetGroupService(),
+	}
+}
+
+func (j *DissolvedGroupPurgeJob) Name() string {
+	return "dissolved_group_purge"
+}
+
+func (j *DissolvedGroupPurgeJob) Interval() time.Duration {
+	return dissolvedGroupPurgeInterval
+}
+
+func (j *DissolvedGroupPurgeJob) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		count, err := j.groupService.PurgeDissolvedGroups(ctx, dissolvedGroupPurgeBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			log.Info("dissolved groups purged", "count", count)
+		}
+
+		// groups failed to purge are listed again, so stop when a batch is not full.
+		if count < dissolvedGroupPurgeBatchSize {
+			return nil
+		}
+	}
+}
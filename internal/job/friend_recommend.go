@@ -0,0 +1,80 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/service"
+)
+
+var (
+	friendRecommendInterval  time.Duration
+	friendRecommendBatchSize int
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&friendRecommendInterval, "friend-recommend-interval", 6*time.Hour,
+		"interval of refreshing friend recommendations")
+	cmd.GlobalFlagSet.IntVar(&friendRecommendBatchSize, "friend-recommend-batch-size", 100,
+		"count of active users scanned per batch when refreshing friend recommendations")
+}
+
+// FriendRecommendJob precomputes "people you may know" for recently active users.
+type FriendRecommendJob struct {
+	friendRecommendationDao *dao.FriendRecommendationDao
+	friendService           *service.FriendService
+}
+
+var _ Job = &FriendRecommendJob{}
+
+func NewFriendRecommendJob() *FriendRecommendJob {
+	return &FriendRecommendJob{
+		friendRecommendationDao: dao.GetFriendRecommendationDao(),
+		friendService:           service.GetFriendService(),
+	}
+}
+
+func (j *FriendRecommendJob) Name() string {
+	return "friend_recommend"
+}
+
+func (j *FriendRecommendJob) Interval() time.Duration {
+	return friendRecommendInterval
+}
+
+func (j *FriendRecommendJob) Run(ctx context.Context) error {
+	since := time.Now().Unix() - data.ActiveUserExpire
+	if err := j.friendRecommendationDao.TrimInactiveUsers(ctx, since); err != nil {
+		return err
+	}
+
+	var cursor uint64
+	for {
+		uids, next, err := j.friendRecommendationDao.ScanActiveUsers(ctx, cursor, int64(friendRecommendBatchSize))
+		if err != nil {
+			return err
+		}
+
+		for _, uid := range uids {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err = j.friendService.RefreshFriendRecommendations(ctx, uid); err != nil {
+				// skip failed user, it will be refreshed in next round.
+				log.Error("refresh friend recommendations error", "uid", uid, "err", err)
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+
+		cursor = next
+	}
+}
@@ -0,0 +1,201 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	redisv8 "github.com/go-redis/redis/v8"
+
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/app"
+)
+
+// Job is a background task which runs periodically.
+type Job interface {
+	// Name returns the name of job, used in logs.
+	Name() string
+	// Interval returns the duration between two runs.
+	Interval() time.Duration
+	// Run runs the job once, ctx will be canceled when runner shutdown.
+	Run(ctx context.Context) error
+}
+
+// Runner runs registered jobs in their own goroutine until shutdown.
+// All replicas start a runner, a redis lock per job makes sure only one of them runs the job per interval.
+type Runner struct {
+	rdb    *redisv8.Client
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(jobs ...Job) *Runner {
+	return &Runner{
+		rdb:  app.GetApplication().Redis,
+		jobs: jobs,
+	}
+}
+
+// Register adds jobs to runner, must be called before Start.
+func (r *Runner) Register(jobs ...Job) {
+	r.jobs = append(r.jobs, jobs...)
+}
+
+func (r *Runner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.run(ctx, j)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, j Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(j.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.runOnce(ctx, j)
+		}
+	}
+}
+
+// runOnce runs j if this replica gets the job lock, the lock is renewed while j is running.
+func (r *Runner) runOnce(ctx context.Context, j Job) {
+	start := time.Now()
+	token := types.NewID().String()
+	if !r.tryLock(ctx, j, token) {
+		return
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	renewDone := make(chan struct{})
+	go func() {
+		defer close(renewDone)
+		r.renewLock(runCtx, cancel, j, token)
+	}()
+
+	err := j.Run(runCtx)
+	cancel()
+	<-renewDone
+	r.releaseLock(j, token, start)
+
+	if err != nil {
+		log.Error("run job error", "job", j.Name(), "err", err)
+		return
+	}
+
+	log.Info("run job done", "job", j.Name(), "cost", time.Since(start).String())
+}
+
+// jobLockLease is the expire time of job lock while job is running, the lock is renewed every third of it.
+// A lock of crashed replica is released after it.
+const jobLockLease = 30 * time.Second
+
+func jobLockKey(j Job) string {
+	return "job_lock:" + j.Name()
+}
+
+// renewJobLockScript extends expire time of job lock if it is still held by given token.
+var renewJobLockScript = redisv8.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseJobLockScript releases job lock held by given token. The lock is kept for ARGV[2] milliseconds
+// if it is positive, so that other replicas with unaligned tickers can not run the job again within the same interval.
+var releaseJobLockScript = redisv8.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return redis.call("DEL", KEYS[1])
+`)
+
+// tryLock reports whether this replica should run j at current tick.
+func (r *Runner) tryLock(ctx context.Context, j Job, token string) bool {
+	ok, err := r.rdb.SetNX(ctx, jobLockKey(j), token, jobLockLease).Result()
+	if err != nil {
+		// skip this round rather than run the job on every replica.
+		log.Error("acquire job lock error", "job", j.Name(), "err", err)
+		return false
+	}
+
+	return ok
+}
+
+// renewLock renews job lock until ctx done, cancel is called if the lock is lost,
+// so that the job stops before another replica runs it.
+func (r *Runner) renewLock(ctx context.Context, cancel context.CancelFunc, j Job, token string) {
+	ticker := time.NewTicker(jobLockLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := renewJobLockScript.Run(ctx, r.rdb, []string{jobLockKey(j)}, token,
+				jobLockLease.Milliseconds()).Int()
+			if err != nil {
+				// lock is still valid until lease expires, try again at next tick.
+				log.Error("renew job lock error", "job", j.Name(), "err", err)
+				continue
+			}
+
+			if n == 0 {
+				log.Error("job lock lost", "job", j.Name())
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// releaseLock releases job lock held by token, it is kept until nine tenths of interval passed since start.
+func (r *Runner) releaseLock(j Job, token string, start time.Time) {
+	// ctx of runner may be canceled by shutdown, the lock should be released anyway.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	keep := time.Until(start.Add(j.Interval() * 9 / 10))
+	err := releaseJobLockScript.Run(ctx, r.rdb, []string{jobLockKey(j)}, token, keep.Milliseconds()).Err()
+	if err != nil {
+		log.Error("release job lock error", "job", j.Name(), "err", err)
+	}
+}
+
+// Shutdown stops all jobs and waits for running jobs to exit or ctx done.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+
+	r.cancel()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/metrics"
+	"github.com/go-goim/user-service/internal/service"
+)
+
+var (
+	memberCountReconcileInterval  time.Duration
+	memberCountReconcileBatchSize int
+	memberCountReconcileDryRun    bool
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&memberCountReconcileInterval, "member-count-reconcile-interval", 6*time.Hour,
+		"interval of reconciling group member count with group_member table")
+	cmd.GlobalFlagSet.IntVar(&memberCountReconcileBatchSize, "member-count-reconcile-batch-size", 500,
+		"count of groups checked per batch when reconciling member count")
+	cmd.GlobalFlagSet.BoolVar(&memberCountReconcileDryRun, "member-count-reconcile-dry-run", false,
+		"only report drifted member count without fixing")
+}
+
+// MemberCountReconcileJob scans all groups in batches, recomputes member count from group_member table
+// and fixes drifted ones. In dry-run mode drifts are only logged and counted.
+type MemberCountReconcileJob struct {
+	groupService *service.GroupService
+	dryRun       bool
+}
+
+var _ Job = &MemberCountReconcileJob{}
+
+func NewMemberCountReconcileJob() *MemberCountReconcileJob {
+	return &MemberCountReconcileJob{
+		groupService: service.GetGroupService(),
+		dryRun:       memberCountReconcileDryRun,
+	}
+}
+
+func (j *MemberCountReconcileJob) Name() string {
+	return "member_count_reconcile"
+}
+
+func (j *MemberCountReconcileJob) Interval() time.Duration {
+	return memberCountReconcileInterval
+}
+
+func (j *MemberCountReconcileJob) Run(ctx context.Context) error {
+	var cursor uint64
+	for {
+		drifts, next, err := j.groupService.ReconcileMemberCounts(ctx, cursor, memberCountReconcileBatchSize, j.dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range drifts {
+			metrics.GroupMemberCountDrift.Inc()
+			if d.Fixed {
+				metrics.GroupMemberCountRepaired.Inc()
+			}
+
+			log.Info("group member count drift found", "gid", d.Gid, "recorded", d.Recorded, "actual", d.Actual,
+				"fixed", d.Fixed, "dry_run", j.dryRun)
+		}
+
+		if next == 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		cursor = next
+	}
+}
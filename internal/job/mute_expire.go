@@ -0,0 +1,68 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/service"
+)
+
+var (
+	muteExpireInterval  time.Duration
+	muteExpireBatchSize int
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&muteExpireInterval, "mute-expire-interval", time.Minute,
+		"interval of clearing expired group and member mutes")
+	cmd.GlobalFlagSet.IntVar(&muteExpireBatchSize, "mute-expire-batch-size", 500,
+		"count of expired mutes cleared per batch")
+}
+
+// MuteExpireJob clears expired mutes of groups and members.
+// Expired mutes are also cleared lazily when checking send message ability,
+// this job makes sure statuses in db and cache are correct for members never sending messages.
+type MuteExpireJob struct {
+	groupService *service.GroupService
+}
+
+var _ Job = &MuteExpireJob{}
+
+func NewMuteExpireJob() *MuteExpireJob {
+	return &MuteExpireJob{
+		groupService: service.GetGroupService(),
+	}
+}
+
+func (j *MuteExpireJob) Name() string {
+	return "mute_expire"
+}
+
+func (j *MuteExpireJob) Interval() time.Duration {
+	return muteExpireInterval
+}
+
+func (j *MuteExpireJob) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		count, err := j.groupService.ExpireMutes(ctx, muteExpireBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			log.Info("expired mutes cleared", "count", count)
+		}
+
+		// rows failed to unmute are listed again, so stop when a batch is not full.
+		if count < muteExpireBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/dao"
+)
+
+var (
+	outboxPurgeInterval  time.Duration
+	outboxPurgeBatchSize int
+	outboxRetention      time.Duration
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&outboxPurgeInterval, "outbox-purge-interval", time.Hour,
+		"interval of purging delivered outbox events")
+	cmd.GlobalFlagSet.IntVar(&outboxPurgeBatchSize, "outbox-purge-batch-size", 1000,
+		"count of delivered outbox events deleted per batch")
+	cmd.GlobalFlagSet.DurationVar(&outboxRetention, "outbox-retention", 7*24*time.Hour,
+		"how long delivered outbox events are kept before purged")
+}
+
+// OutboxPurgeJob deletes delivered outbox rows older than retention, so that outbox table won't grow forever.
+type OutboxPurgeJob struct {
+	outboxDao *dao.OutboxDao
+}
+
+var _ Job = &OutboxPurgeJob{}
+
+func NewOutboxPurgeJob() *OutboxPurgeJob {
+	return &OutboxPurgeJob{
+		outboxDao: dao.GetOutboxDao(),
+	}
+}
+
+func (j *OutboxPurgeJob) Name() string {
+	return "outbox_purge"
+}
+
+func (j *OutboxPurgeJob) Interval() time.Duration {
+	return outboxPurgeInterval
+}
+
+func (j *OutboxPurgeJob) Run(ctx context.Context) error {
+	before := time.Now().Add(-outboxRetention).Unix()
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		count, err := j.outboxDao.PurgeDeliveredOutbox(ctx, before, outboxPurgeBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			log.Info("delivered outbox purged", "count", count)
+		}
+
+		if count < int64(outboxPurgeBatchSize) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/log"
+
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/event"
+	"github.com/go-goim/user-service/internal/metrics"
+)
+
+var (
+	outboxRelayInterval  time.Duration
+	outboxRelayBatchSize int
+	outboxRelayLease     time.Duration
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&outboxRelayInterval, "outbox-relay-interval", time.Second,
+		"interval of publishing pending outbox events")
+	cmd.GlobalFlagSet.IntVar(&outboxRelayBatchSize, "outbox-relay-batch-size", 100,
+		"count of outbox events published per batch")
+	cmd.GlobalFlagSet.DurationVar(&outboxRelayLease, "outbox-relay-lease", 30*time.Second,
+		"duration claimed outbox events are hidden from other relays, should be longer than publishing a batch")
+}
+
+// OutboxRelayJob publishes pending outbox rows to mq with at-least-once delivery.
+// Rows are marked delivered only after sent successfully, so a row may be sent more than once
+// if process dies in between, consumers should deduplicate events by event id.
+type OutboxRelayJob struct {
+	outboxDao *dao.OutboxDao
+	publisher *event.Publisher
+}
+
+var _ Job = &OutboxRelayJob{}
+
+func NewOutboxRelayJob() *OutboxRelayJob {
+	return &OutboxRelayJob{
+		outboxDao: dao.GetOutboxDao(),
+		publisher: event.GetPublisher(),
+	}
+}
+
+func (j *OutboxRelayJob) Name() string {
+	return "outbox_relay"
+}
+
+func (j *OutboxRelayJob) Interval() time.Duration {
+	return outboxRelayInterval
+}
+
+func (j *OutboxRelayJob) Run(ctx context.Context) error {
+	defer j.reportLag(ctx)
+
+	for {
+		now := time.Now().Unix()
+		list, err := j.outboxDao.ClaimPendingOutbox(ctx, now, int64(outboxRelayLease/time.Second), outboxRelayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		delivered := make([]uint64, 0, len(list))
+		for _, o := range list {
+			if err = j.publisher.PublishOutbox(ctx, o); err != nil {
+				metrics.OutboxPublished.WithLabelValues(metrics.ResultFailure).Inc()
+				log.Error("publish outbox error", "id", o.ID, "event_id", o.EventID, "attempts", o.Attempts, "err", err)
+
+				o.SetFailed(now, err)
+				if err = j.outboxDao.MarkFailed(ctx, o); err != nil {
+					// rows sent in this batch are not marked yet, they will be sent again after lease.
+					return err
+				}
+				continue
+			}
+
+			metrics.OutboxPublished.WithLabelValues(metrics.ResultSuccess).Inc()
+			delivered = append(delivered, o.ID)
+		}
+
+		if err = j.outboxDao.MarkDelivered(ctx, delivered); err != nil {
+			return err
+		}
+
+		if len(list) < outboxRelayBatchSize {
+			return nil
+		}
+	}
+}
+
+func (j *OutboxRelayJob) reportLag(ctx context.Context) {
+	count, err := j.outboxDao.CountPendingOutbox(ctx)
+	if err != nil {
+		log.Error("count pending outbox error", "err", err)
+		return
+	}
+
+	metrics.OutboxPending.Set(float64(count))
+
+	o, err := j.outboxDao.GetOldestPendingOutbox(ctx)
+	if err != nil {
+		log.Error("get oldest pending outbox error", "err", err)
+		return
+	}
+
+	if o == nil {
+		metrics.OutboxLagSeconds.Set(0)
+		return
+	}
+
+	metrics.OutboxLagSeconds.Set(float64(time.Now().Unix() - o.CreatedAt))
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	namespace = "goim"
+	subsystem = "user_service"
+)
+
+var (
+	// OutboxLagSeconds is the age of the oldest pending outbox row, 0 if there is no pending row.
+	OutboxLagSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "outbox_lag_seconds",
+		Help:      "Age in seconds of the oldest pending outbox event.",
+	})
+
+	// OutboxPending is the count of pending outbox rows.
+	OutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "outbox_pending",
+		Help:      "Count of pending outbox events.",
+	})
+
+	// OutboxPublished counts publish attempts of outbox rows by result.
+	OutboxPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "outbox_published_total",
+		Help:      "Count of outbox publish attempts partitioned by result.",
+	}, []string{"result"})
+
+	// CacheReconcileMismatch counts differences between cache and db found by reconciler.
+	CacheReconcileMismatch = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "cache_reconcile_mismatch_total",
+		Help:      "Count of cache entries mismatched with db partitioned by cache and kind.",
+	}, []string{"cache", "kind"})
+
+	// CacheReconcileRepaired counts cache entries repaired by reconciler, always 0 in dry-run mode.
+	CacheReconcileRepaired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "cache_reconcile_repaired_total",
+		Help:      "Count of cache entries repaired by reconciler partitioned by cache.",
+	}, []string{"cache"})
+
+	// GroupMemberCountDrift counts groups whose member count differs from member rows.
+	GroupMemberCountDrift = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "group_member_count_drift_total",
+		Help:      "Count of groups whose member count drifted from member rows.",
+	})
+
+	// GroupMemberCountRepaired counts member counts fixed by reconciler, always 0 in dry-run mode.
+	GroupMemberCountRepaired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "group_member_count_repaired_total",
+		Help:      "Count of group member counts repaired by reconciler.",
+	})
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
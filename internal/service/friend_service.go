@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/go-goim/api/errors"
 
 	// api
 	messagev1 "github.com/go-goim/api/message/v1"
+	eventv1 "github.com/go-goim/api/user/event/v1"
 	friendpb "github.com/go-goim/api/user/friend/v1"
 	"github.com/go-goim/core/pkg/util"
 
@@ -21,13 +24,16 @@ import (
 	"github.com/go-goim/user-service/internal/app"
 	"github.com/go-goim/user-service/internal/dao"
 	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/event"
 )
 
 // FriendService implements friendpb.FriendServiceServer
 type FriendService struct {
-	friendDao        *dao.FriendDao
-	friendRequestDao *dao.FriendRequestDao
-	userDao          *dao.UserDao
+	friendDao               *dao.FriendDao
+	friendRequestDao        *dao.FriendRequestDao
+	friendRecommendationDao *dao.FriendRecommendationDao
+	userDao                 *dao.UserDao
+	publisher               *event.Publisher
 	friendpb.UnimplementedFriendServiceServer
 }
 
@@ -40,9 +46,11 @@ var (
 func GetFriendService() *FriendService {
 	friendServiceOnce.Do(func() {
 		friendService = &FriendService{
-			friendDao:        dao.GetUserRelationDao(),
-			friendRequestDao: dao.GetFriendRequestDao(),
-			userDao:          dao.GetUserDao(),
+			friendDao:               dao.GetUserRelationDao(),
+			friendRequestDao:        dao.GetFriendRequestDao(),
+			friendRecommendationDao: dao.GetFriendRecommendationDao(),
+			userDao:                 dao.GetUserDao(),
+			publisher:               event.GetPublisher(),
 		}
 	})
 	return friendService
@@ -99,6 +107,16 @@ func (s *FriendService) AddFriend(ctx context.Context, req *friendpb.BaseFriendR
 		return rsp, nil
 	}
 
+	// friend had sent request to me, make friends directly
+	ok, err = s.matchFriendRequest(ctx, uid, friendUser.UID, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return rsp, nil
+	}
+
 	base := &friendpb.BaseFriendRequest{
 		Uid:       req.Uid,
 		FriendUid: friendUser.UID.Int64(),
@@ -139,7 +157,7 @@ func (s *FriendService) addAutomatically(ctx context.Context, me, friend *data.F
 	if me == nil {
 		// create me -> friend relation
 		me = &data.Friend{
-			UID:       me.UID,
+			UID:       friend.FriendUID,
 			FriendUID: friend.UID,
 			Status:    friendpb.FriendStatus_FRIEND,
 		}
@@ -148,6 +166,8 @@ func (s *FriendService) addAutomatically(ctx context.Context, me, friend *data.F
 			return false, err
 		}
 
+		s.friendDao.InvalidFriendSetCache(ctx, me.UID, me.FriendUID)
+		s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIENDSHIP_CREATED, me.UID, me.FriendUID, 0)
 		rsp.Result.Status = friendpb.AddFriendStatus_ADD_FRIEND_SUCCESS
 		return true, nil
 	}
@@ -157,10 +177,80 @@ func (s *FriendService) addAutomatically(ctx context.Context, me, friend *data.F
 		return false, err
 	}
 
+	s.friendDao.InvalidFriendSetCache(ctx, me.UID, me.FriendUID)
+
+	s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIENDSHIP_CREATED, me.UID, me.FriendUID, 0)
 	rsp.Result.Status = friendpb.AddFriendStatus_ADD_FRIEND_SUCCESS
 	return true, nil
 }
 
+// matchFriendRequest checks whether there is a pending request from friend to me,
+// if so, accept it and mark my request as accepted in one transaction.
+// It returns true if the request is matched or refused by friend limit.
+func (s *FriendService) matchFriendRequest(ctx context.Context, uid, friendUID types.ID,
+	rsp *friendpb.AddFriendResponse) (bool, error) {
+	reverse, err := s.friendRequestDao.GetFriendRequest(ctx, friendUID, uid)
+	if err != nil {
+		return false, err
+	}
+
+	if reverse == nil || !reverse.IsRequested() {
+		return false, nil
+	}
+
+	count, err := s.friendDao.CountFriendsByStatus(ctx, uid, friendpb.FriendStatus_FRIEND)
+	if err != nil {
+		return false, err
+	}
+
+	fr, err := s.friendRequestDao.GetFriendRequest(ctx, uid, friendUID)
+	if err != nil {
+		return false, err
+	}
+
+	var matched bool
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		ok, err1 := s.acceptFriendRequest(ctx2, reverse, int(count))
+		if err1 != nil || !ok {
+			return err1
+		}
+
+		matched = true
+		if fr == nil {
+			fr = &data.FriendRequest{
+				UID:       uid,
+				FriendUID: friendUID,
+				Status:    friendpb.FriendRequestStatus_ACCEPTED,
+			}
+			err1 = s.friendRequestDao.CreateFriendRequest(ctx2, fr)
+		} else {
+			fr.SetAccepted()
+			fr.UpdatedAt = time.Now().Unix()
+			err1 = s.friendRequestDao.UpdateFriendRequest(ctx2, fr)
+		}
+		if err1 != nil {
+			return err1
+		}
+
+		// both requests are accepted, consumers should see both of them.
+		return s.addFriendEvent(ctx2, eventv1.FriendEventType_FRIEND_REQUEST_ACCEPTED, fr.UID, fr.FriendUID, fr.ID)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if !matched {
+		rsp.Error = errors.ErrorCode_FriendLimitExceed.Err2()
+		return true, nil
+	}
+
+	s.setFriendStatusToCache(ctx, uid, friendUID)
+
+	rsp.Result.Status = friendpb.AddFriendStatus_MATCHED
+	rsp.Result.FriendRequest = fr.ToProto()
+	return true, nil
+}
+
 // friend has not blocked me and has no relation with me(no data or status is stranger)
 // me has not blocked the friend and may have relation with the friend(no data or status in [friend, stranger])
 func (s *FriendService) sendFriendRequest(ctx context.Context, req *friendpb.BaseFriendRequest,
@@ -187,6 +277,7 @@ func (s *FriendService) sendFriendRequest(ctx context.Context, req *friendpb.Bas
 			return err
 		}
 
+		s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIEND_REQUEST_SENT, fr.UID, fr.FriendUID, fr.ID)
 		rsp.Result.Status = friendpb.AddFriendStatus_SEND_REQUEST_SUCCESS
 		rsp.Result.FriendRequest = fr.ToProto()
 		return nil
@@ -206,6 +297,7 @@ func (s *FriendService) sendFriendRequest(ctx context.Context, req *friendpb.Bas
 			return err
 		}
 
+		s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIEND_REQUEST_SENT, fr.UID, fr.FriendUID, fr.ID)
 		rsp.Result.Status = friendpb.AddFriendStatus_SEND_REQUEST_SUCCESS
 		rsp.Result.FriendRequest = fr.ToProto()
 	}
@@ -217,6 +309,7 @@ func (s *FriendService) sendFriendRequest(ctx context.Context, req *friendpb.Bas
 			return err
 		}
 
+		s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIEND_REQUEST_SENT, fr.UID, fr.FriendUID, fr.ID)
 		rsp.Result.Status = friendpb.AddFriendStatus_SEND_REQUEST_SUCCESS
 		rsp.Result.FriendRequest = fr.ToProto()
 	}
@@ -253,76 +346,80 @@ func (s *FriendService) ConfirmFriendRequest(ctx context.Context, req *friendpb.
 			return nil, err
 		}
 
+		s.publishFriendEvent(ctx, eventv1.FriendEventType_FRIEND_REQUEST_REJECTED, fr.UID, fr.FriendUID, fr.ID)
 		return errors.ErrorOK(), nil
 	}
 
-	// accept the friend request
-
-	me, err := s.friendDao.GetFriend(ctx, fr.UID, fr.FriendUID)
+	// accept the friend request, friend limit of both sides is checked same as batch confirm and matching.
+	count, err := s.friendDao.CountFriendsByStatus(ctx, fr.FriendUID, friendpb.FriendStatus_FRIEND)
 	if err != nil {
 		return nil, err
 	}
 
-	friend, err := s.friendDao.GetFriend(ctx, fr.FriendUID, fr.UID)
+	var accepted bool
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		var err1 error
+		accepted, err1 = s.acceptFriendRequest(ctx2, fr, int(count))
+		return err1
+	})
 	if err != nil {
-		return nil, err
+		return errors.ErrorCode_DBError.WithError(err), nil
 	}
 
-	if me != nil && me.IsFriend() && friend != nil && friend.IsFriend() {
-		fr.SetAccepted()
-		if err = s.friendRequestDao.UpdateFriendRequest(ctx, fr); err != nil {
-			return nil, err
-		}
-
-		return errors.ErrorOK(), nil
+	if !accepted {
+		return errors.ErrorCode_FriendLimitExceed.Err2(), nil
 	}
 
-	// big transaction here
-	err = db.Transaction(ctx, func(ctx2 context.Context) error {
-		// step 1: update friend request status to accepted
-		fr.SetAccepted()
-		if err = s.friendRequestDao.UpdateFriendRequest(ctx2, fr); err != nil {
-			return err
-		}
-
-		// step 2: create or update friend relationship me -> friend
-		if err = s.createOrSetFriend(ctx2, fr.UID, fr.FriendUID, me); err != nil {
-			return err
-		}
+	// set friend status in the cache
+	// only set when the friend request is accepted.
+	s.setFriendStatusToCache(ctx, fr.UID, fr.FriendUID)
 
-		// step 3: create or update friend relationship friend -> me
-		if err = s.createOrSetFriend(ctx2, fr.FriendUID, fr.UID, friend); err != nil {
-			return err
-		}
+	return errors.ErrorOK(), nil
+}
 
-		return nil
-	})
+// addFriendEvent writes relationship change event to outbox,
+// it should be called in the same transaction which changes the relationship.
+func (s *FriendService) addFriendEvent(ctx context.Context, typ eventv1.FriendEventType, uid, friendUID types.ID,
+	friendRequestID uint64) error {
+	e := event.NewFriendEvent(typ, uid, friendUID)
+	e.FriendRequestId = friendRequestID
+	return s.publisher.EnqueueFriendEvent(ctx, e)
+}
 
-	if err != nil {
-		return errors.ErrorCode_DBError.WithError(err), nil
+// publishFriendEvent writes relationship change event to outbox for changes made without transaction,
+// error is only logged because the relationship has been changed already.
+func (s *FriendService) publishFriendEvent(ctx context.Context, typ eventv1.FriendEventType, uid, friendUID types.ID,
+	friendRequestID uint64) {
+	if err := s.addFriendEvent(ctx, typ, uid, friendUID, friendRequestID); err != nil {
+		log.Error("add friend event error", "type", typ.String(), "uid", uid, "friend_uid", friendUID, "err", err)
 	}
+}
 
-	// set friend status in the cache
-	// only set when the friend request is accepted.
-	if err = s.friendDao.SetFriendStatusToCache(ctx, fr.UID, fr.FriendUID); err != nil {
-		log.Error("set friend status to cache error",
-			"err", err, "uid", fr.UID, "friend_uid", fr.FriendUID)
-
-		// too complicated handling of retry, need to think about it
-		err1 := retry.RetryWithQueue(func() error {
-			return s.friendDao.SetFriendStatusToCache(ctx, fr.UID, fr.FriendUID)
-		}, app.GetApplication().Producer, "retry_event_topic", map[string]interface{}{
-			"uid":        fr.UID,
-			"friend_uid": fr.FriendUID,
-			"event":      "set_friend_status_to_cache",
-		})
+// setFriendStatusToCache set friend status to cache, retry with queue if failed.
+// Friend uid sets of both users are invalidated too, so it must be called after transaction committed.
+func (s *FriendService) setFriendStatusToCache(ctx context.Context, uid, friendUID types.ID) {
+	s.friendDao.InvalidFriendSetCache(ctx, uid, friendUID)
 
-		if err1 != nil {
-			log.Error("retry set friend status to cache error", "err", err1, "uid", fr.UID, "friend_uid", fr.FriendUID)
-		}
+	err := s.friendDao.SetFriendStatusToCache(ctx, uid, friendUID)
+	if err == nil {
+		return
 	}
 
-	return errors.ErrorOK(), nil
+	log.Error("set friend status to cache error",
+		"err", err, "uid", uid, "friend_uid", friendUID)
+
+	// too complicated handling of retry, need to think about it
+	err1 := retry.RetryWithQueue(func() error {
+		return s.friendDao.SetFriendStatusToCache(ctx, uid, friendUID)
+	}, app.GetApplication().Producer, event.RetryEventTopic, map[string]interface{}{
+		"uid":        uid.Int64(),
+		"friend_uid": friendUID.Int64(),
+		"event":      event.RetryEventSetFriendStatusToCache,
+	})
+
+	if err1 != nil {
+		log.Error("retry set friend status to cache error", "err", err1, "uid", uid, "friend_uid", friendUID)
+	}
 }
 
 func (s *FriendService) createOrSetFriend(ctx context.Context, uid, friendUID types.ID, f *data.Friend) error {
@@ -340,6 +437,245 @@ func (s *FriendService) createOrSetFriend(ctx context.Context, uid, friendUID ty
 	return s.friendDao.CreateFriend(ctx, f)
 }
 
+// BatchConfirmFriendRequest accept or reject many friend requests send to uid in one call.
+// Requests are processed in chunks, each chunk runs in its own transaction.
+// With AllPending at most data.MaxBatchConfirmCount requests are confirmed, HasMore is set if more are pending.
+func (s *FriendService) BatchConfirmFriendRequest(ctx context.Context, req *friendpb.BatchConfirmFriendRequestRequest) (
+	*friendpb.BatchConfirmFriendRequestResponse, error) {
+	var (
+		uid = types.ID(req.Uid)
+		rsp = &friendpb.BatchConfirmFriendRequestResponse{
+			Error: errors.ErrorOK(),
+		}
+		frList []*data.FriendRequest
+		err    error
+	)
+
+	if req.AllPending {
+		// load one more request to know whether there are more pending requests than one batch.
+		frList, err = s.friendRequestDao.ListFriendRequestsToUser(ctx, uid, int(friendpb.FriendRequestStatus_REQUESTED),
+			data.MaxBatchConfirmCount+1)
+	} else {
+		if len(req.FriendRequestIds) > data.MaxBatchConfirmCount {
+			rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("too many friend requests")
+			return rsp, nil
+		}
+		frList, err = s.friendRequestDao.GetFriendRequestsByIDs(ctx, req.FriendRequestIds)
+	}
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	// caller should call again to confirm the rest pending requests.
+	if len(frList) > data.MaxBatchConfirmCount {
+		frList = frList[:data.MaxBatchConfirmCount]
+		rsp.HasMore = true
+	}
+
+	var (
+		frMap   = make(map[uint64]*data.FriendRequest, len(frList))
+		results = make(map[uint64]*friendpb.ConfirmFriendRequestResult)
+		valid   = make([]*data.FriendRequest, 0, len(frList))
+		ids     = req.FriendRequestIds
+	)
+
+	for _, fr := range frList {
+		frMap[fr.ID] = fr
+	}
+
+	if req.AllPending {
+		ids = make([]uint64, len(frList))
+		for i, fr := range frList {
+			ids[i] = fr.ID
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := results[id]; ok {
+			continue // duplicated id
+		}
+
+		result := &friendpb.ConfirmFriendRequestResult{
+			FriendRequestId: id,
+			Error:           errors.ErrorOK(),
+		}
+		results[id] = result
+		rsp.Results = append(rsp.Results, result)
+
+		fr, ok := frMap[id]
+		if !ok || fr.FriendUID != uid {
+			result.Error = errors.ErrorCode_FriendRequestNotExist.Err2()
+			continue
+		}
+
+		if !fr.IsRequested() {
+			result.Error = errors.ErrorCode_FriendRequestStatusError.
+				WithMessage("current friend request status cannot be confirmed")
+			continue
+		}
+
+		valid = append(valid, fr)
+	}
+
+	if req.Action == friendpb.ConfirmFriendRequestAction_REJECT {
+		s.batchRejectFriendRequest(ctx, valid, results)
+		return rsp, nil
+	}
+
+	count, err := s.friendDao.CountFriendsByStatus(ctx, uid, friendpb.FriendStatus_FRIEND)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	s.batchAcceptFriendRequest(ctx, valid, int(count), results)
+	return rsp, nil
+}
+
+func (s *FriendService) batchRejectFriendRequest(ctx context.Context, frList []*data.FriendRequest,
+	results map[uint64]*friendpb.ConfirmFriendRequestResult) {
+	for start := 0; start < len(frList); start += data.BatchConfirmChunkSize {
+		end := start + data.BatchConfirmChunkSize
+		if end > len(frList) {
+			end = len(frList)
+		}
+
+		chunk := frList[start:end]
+		err := db.Transaction(ctx, func(ctx2 context.Context) error {
+			for _, fr := range chunk {
+				fr.SetRejected()
+				fr.UpdatedAt = time.Now().Unix()
+				if err := s.friendRequestDao.UpdateFriendRequest(ctx2, fr); err != nil {
+					return err
+				}
+
+				err := s.addFriendEvent(ctx2, eventv1.FriendEventType_FRIEND_REQUEST_REJECTED, fr.UID, fr.FriendUID, fr.ID)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			for _, fr := range chunk {
+				results[fr.ID].Error = errors.ErrorCode_DBError.WithError(err)
+			}
+		}
+	}
+}
+
+// batchAcceptFriendRequest accept friend requests in chunks.
+// friendCount is the current friend count of the receiver, requests over data.UserMaxFriendCount are refused.
+func (s *FriendService) batchAcceptFriendRequest(ctx context.Context, frList []*data.FriendRequest, friendCount int,
+	results map[uint64]*friendpb.ConfirmFriendRequestResult) {
+	for start := 0; start < len(frList); start += data.BatchConfirmChunkSize {
+		end := start + data.BatchConfirmChunkSize
+		if end > len(frList) {
+			end = len(frList)
+		}
+
+		var (
+			chunk    = frList[start:end]
+			accepted = make([]*data.FriendRequest, 0, len(chunk))
+			added    = 0
+		)
+
+		err := db.Transaction(ctx, func(ctx2 context.Context) error {
+			for _, fr := range chunk {
+				ok, err := s.acceptFriendRequest(ctx2, fr, friendCount+added)
+				if err != nil {
+					return err
+				}
+
+				if !ok {
+					results[fr.ID].Error = errors.ErrorCode_FriendLimitExceed.Err2()
+					continue
+				}
+
+				accepted = append(accepted, fr)
+				added++
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			for _, fr := range chunk {
+				results[fr.ID].Error = errors.ErrorCode_DBError.WithError(err)
+			}
+			continue
+		}
+
+		friendCount += added
+		for _, fr := range accepted {
+			s.setFriendStatusToCache(ctx, fr.UID, fr.FriendUID)
+		}
+	}
+}
+
+// acceptFriendRequest accept friend request and create friend relationship of both sides, must be called in transaction.
+// It returns false if the friend count of either side reaches data.UserMaxFriendCount.
+func (s *FriendService) acceptFriendRequest(ctx context.Context, fr *data.FriendRequest, friendCount int) (bool, error) {
+	me, err := s.friendDao.GetFriend(ctx, fr.UID, fr.FriendUID)
+	if err != nil {
+		return false, err
+	}
+
+	friend, err := s.friendDao.GetFriend(ctx, fr.FriendUID, fr.UID)
+	if err != nil {
+		return false, err
+	}
+
+	alreadyFriend := me != nil && me.IsFriend() && friend != nil && friend.IsFriend()
+	if !alreadyFriend {
+		if friendCount >= data.UserMaxFriendCount {
+			return false, nil
+		}
+
+		requesterCount, err := s.friendDao.CountFriendsByStatus(ctx, fr.UID, friendpb.FriendStatus_FRIEND)
+		if err != nil {
+			return false, err
+		}
+
+		if requesterCount >= data.UserMaxFriendCount {
+			return false, nil
+		}
+	}
+
+	fr.SetAccepted()
+	fr.UpdatedAt = time.Now().Unix()
+	if err = s.friendRequestDao.UpdateFriendRequest(ctx, fr); err != nil {
+		return false, err
+	}
+
+	err = s.addFriendEvent(ctx, eventv1.FriendEventType_FRIEND_REQUEST_ACCEPTED, fr.UID, fr.FriendUID, fr.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if alreadyFriend {
+		return true, nil
+	}
+
+	if err = s.createOrSetFriend(ctx, fr.UID, fr.FriendUID, me); err != nil {
+		return false, err
+	}
+
+	if err = s.createOrSetFriend(ctx, fr.FriendUID, fr.UID, friend); err != nil {
+		return false, err
+	}
+
+	err = s.addFriendEvent(ctx, eventv1.FriendEventType_FRIENDSHIP_CREATED, fr.UID, fr.FriendUID, fr.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (s *FriendService) GetFriendRequest(ctx context.Context, req *friendpb.BaseFriendRequest) (
 	*friendpb.GetFriendRequestResponse, error) {
 	var (
@@ -510,9 +846,22 @@ func (s *FriendService) UpdateFriendStatus(ctx context.Context, req *friendpb.Up
 		return errors.ErrorCode_DBError.WithError(err), nil
 	}
 
+	s.friendDao.InvalidFriendSetCache(ctx, uid, fuid)
+
+	if typ, ok := friendStatusEventTypes[req.Status]; ok {
+		s.publishFriendEvent(ctx, typ, uid, fuid, 0)
+	}
+
 	return errors.ErrorOK(), nil
 }
 
+// friendStatusEventTypes maps target status of UpdateFriendStatus to event type.
+var friendStatusEventTypes = map[friendpb.FriendStatus]eventv1.FriendEventType{
+	friendpb.FriendStatus_STRANGER:  eventv1.FriendEventType_FRIENDSHIP_REMOVED,
+	friendpb.FriendStatus_BLOCKED:   eventv1.FriendEventType_FRIENDSHIP_BLOCKED,
+	friendpb.FriendStatus_UNBLOCKED: eventv1.FriendEventType_FRIENDSHIP_UNBLOCKED,
+}
+
 // delete or block friend.
 func (s *FriendService) onUnfriend(ctx context.Context, uid, friendUID types.ID) error {
 	return s.friendDao.DeleteFriendStatusFromCache(ctx, uid, friendUID)
@@ -533,6 +882,423 @@ func (s *FriendService) onUnblock(ctx context.Context, uid, friendUID types.ID)
 	return nil
 }
 
+// GetMutualFriends returns count and paged list of friends shared by uid and friend_uid.
+func (s *FriendService) GetMutualFriends(ctx context.Context, req *friendpb.GetMutualFriendsRequest) (
+	*friendpb.GetMutualFriendsResponse, error) {
+	var (
+		uid  = types.ID(req.Uid)
+		fuid = types.ID(req.FriendUid)
+		rsp  = &friendpb.GetMutualFriendsResponse{
+			Error: errors.ErrorOK(),
+		}
+	)
+
+	// no mutual friends if anyone blocked the other.
+	me, err := s.friendDao.GetFriend(ctx, uid, fuid)
+	if err != nil {
+		return nil, err
+	}
+
+	other, err := s.friendDao.GetFriend(ctx, fuid, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if (me != nil && me.IsBlocked()) || (other != nil && other.IsBlocked()) {
+		return rsp, nil
+	}
+
+	uids, err := s.friendDao.ListMutualFriendUIDs(ctx, uid, fuid)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp.Count = int32(len(uids))
+	// sort to make pagination stable
+	sort.Slice(uids, func(i, j int) bool {
+		return uids[i] < uids[j]
+	})
+
+	page, pageSize := int(req.Page), int(req.PageSize)
+	if page <= 0 {
+		page = 1
+	}
+
+	if pageSize <= 0 || pageSize > data.MaxPageSize {
+		pageSize = data.DefaultPageSize
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(uids) {
+		return rsp, nil
+	}
+
+	end := start + pageSize
+	if end > len(uids) {
+		end = len(uids)
+	}
+
+	userList, err := s.userDao.ListUsers(ctx, uids[start:end]...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range userList {
+		rsp.FriendList = append(rsp.FriendList, &friendpb.Friend{
+			Uid:          uid.Int64(),
+			FriendUid:    u.UID.Int64(),
+			FriendName:   u.Name,
+			FriendAvatar: u.Avatar,
+			Status:       friendpb.FriendStatus_FRIEND,
+		})
+	}
+
+	return rsp, nil
+}
+
+/*
+ * handle block list logic
+ */
+
+// BlockUser block any user no matter there is a relation between them or not.
+// Pending friend request from blocked user will be rejected.
+func (s *FriendService) BlockUser(ctx context.Context, req *friendpb.BaseFriendRequest) (*errors.Error, error) {
+	var (
+		uid  = types.ID(req.Uid)
+		fuid = types.ID(req.FriendUid)
+	)
+
+	if uid == fuid {
+		return errors.ErrorCode_InvalidParams.WithMessage("cannot block yourself"), nil
+	}
+
+	target, err := s.userDao.GetUserByUID(ctx, fuid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if target == nil {
+		return errors.ErrorCode_UserNotExist.Err2(), nil
+	}
+
+	f, err := s.friendDao.GetFriend(ctx, uid, fuid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if f != nil && f.IsBlocked() {
+		return errors.ErrorOK(), nil
+	}
+
+	fr, err := s.friendRequestDao.GetFriendRequest(ctx, fuid, uid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		if f == nil {
+			f = &data.Friend{
+				UID:       uid,
+				FriendUID: fuid,
+				Status:    friendpb.FriendStatus_BLOCKED,
+			}
+
+			if err1 := s.friendDao.CreateFriend(ctx2, f); err1 != nil {
+				return err1
+			}
+		} else {
+			// block is allowed from any status, so set status directly instead of SetBlocked.
+			f.Status = friendpb.FriendStatus_BLOCKED
+			if err1 := s.friendDao.UpdateFriendStatus(ctx2, f); err1 != nil {
+				return err1
+			}
+		}
+
+		if fr != nil && fr.IsRequested() {
+			fr.SetRejected()
+			fr.UpdatedAt = time.Now().Unix()
+			if err1 := s.friendRequestDao.UpdateFriendRequest(ctx2, fr); err1 != nil {
+				return err1
+			}
+
+			err1 := s.addFriendEvent(ctx2, eventv1.FriendEventType_FRIEND_REQUEST_REJECTED, fr.UID, fr.FriendUID, fr.ID)
+			if err1 != nil {
+				return err1
+			}
+		}
+
+		return s.addFriendEvent(ctx2, eventv1.FriendEventType_FRIENDSHIP_BLOCKED, uid, fuid, 0)
+	})
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	s.friendDao.InvalidFriendSetCache(ctx, uid, fuid)
+	if err = s.onUnfriend(ctx, uid, fuid); err != nil {
+		return errors.ErrorCode_CacheError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// UnblockUser unblock user blocked by BlockUser or UpdateFriendStatus.
+// The relation will be restored to friend if the other side still treats me as friend, otherwise stranger.
+func (s *FriendService) UnblockUser(ctx context.Context, req *friendpb.BaseFriendRequest) (*errors.Error, error) {
+	var (
+		uid  = types.ID(req.Uid)
+		fuid = types.ID(req.FriendUid)
+	)
+
+	f, err := s.friendDao.GetFriend(ctx, uid, fuid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if f == nil || !f.IsBlocked() {
+		return errors.ErrorCode_RelationNotExist.WithMessage("user not blocked"), nil
+	}
+
+	friend, err := s.friendDao.GetFriend(ctx, fuid, uid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	f.Status = friendpb.FriendStatus_STRANGER
+	if friend != nil && friend.IsFriend() {
+		f.Status = friendpb.FriendStatus_FRIEND
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		if err1 := s.friendDao.UpdateFriendStatus(ctx2, f); err1 != nil {
+			return err1
+		}
+
+		return s.addFriendEvent(ctx2, eventv1.FriendEventType_FRIENDSHIP_UNBLOCKED, uid, fuid, 0)
+	})
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	s.friendDao.InvalidFriendSetCache(ctx, uid, fuid)
+	if f.IsFriend() {
+		if err = s.friendDao.SetFriendStatusToCache(ctx, uid, fuid); err != nil {
+			return errors.ErrorCode_CacheError.WithError(err), nil
+		}
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+func (s *FriendService) ListBlockedUsers(ctx context.Context, req *friendpb.ListBlockedUsersRequest) (
+	*friendpb.QueryFriendListResponse, error) {
+	blocked, err := s.friendDao.GetFriendsByStatus(ctx, types.ID(req.Uid), friendpb.FriendStatus_BLOCKED,
+		int(req.Page), int(req.PageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rsp = &friendpb.QueryFriendListResponse{
+			Error: errors.ErrorOK(),
+		}
+		blockedUIDList = make([]types.ID, len(blocked))
+		userMap        = make(map[int64]*data.User)
+	)
+	for i, f := range blocked {
+		rsp.FriendList = append(rsp.FriendList, f.ToProtoFriend())
+		blockedUIDList[i] = f.FriendUID
+	}
+
+	userList, err := s.userDao.ListUsers(ctx, blockedUIDList...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, u := range userList {
+		userMap[u.UID.Int64()] = userList[i]
+	}
+
+	for _, ur := range rsp.FriendList {
+		if u, ok := userMap[ur.FriendUid]; ok {
+			ur.FriendName = u.Name
+			ur.FriendAvatar = u.Avatar
+		}
+	}
+
+	return rsp, nil
+}
+
+/*
+ * handle friend recommendation logic
+ */
+
+// ListFriendRecommendations list precomputed "people you may know" of given uid.
+func (s *FriendService) ListFriendRecommendations(ctx context.Context, req *friendpb.ListFriendRecommendationsRequest) (
+	*friendpb.ListFriendRecommendationsResponse, error) {
+	var (
+		uid = types.ID(req.Uid)
+		rsp = &friendpb.ListFriendRecommendationsResponse{
+			Error: errors.ErrorOK(),
+		}
+	)
+
+	s.markUserActive(ctx, uid)
+	list, err := s.friendRecommendationDao.ListRecommendations(ctx, uid, int(req.Page), int(req.PageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return rsp, nil
+	}
+
+	targetUIDList := make([]types.ID, len(list))
+	for i, r := range list {
+		targetUIDList[i] = r.TargetUID
+	}
+
+	userList, err := s.userDao.ListUsers(ctx, targetUIDList...)
+	if err != nil {
+		return nil, err
+	}
+
+	userMap := make(map[types.ID]*data.User, len(userList))
+	for i, u := range userList {
+		userMap[u.UID] = userList[i]
+	}
+
+	for _, r := range list {
+		u, ok := userMap[r.TargetUID]
+		if !ok {
+			continue
+		}
+
+		pr := r.ToProto()
+		pr.Name = u.Name
+		pr.Avatar = u.Avatar
+		rsp.Recommendations = append(rsp.Recommendations, pr)
+	}
+
+	return rsp, nil
+}
+
+// DismissFriendRecommendation hides friend_uid from recommendations of uid.
+func (s *FriendService) DismissFriendRecommendation(ctx context.Context, req *friendpb.BaseFriendRequest) (
+	*errors.Error, error) {
+	err := s.friendRecommendationDao.DismissRecommendation(ctx, types.ID(req.Uid), types.ID(req.FriendUid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// ReportProfileView records uid viewed profile of friend_uid, which is used to rank recommendations.
+func (s *FriendService) ReportProfileView(ctx context.Context, req *friendpb.BaseFriendRequest) (
+	*errors.Error, error) {
+	if req.Uid == req.FriendUid {
+		return errors.ErrorOK(), nil
+	}
+
+	err := s.friendRecommendationDao.AddProfileView(ctx, types.ID(req.Uid), types.ID(req.FriendUid))
+	if err != nil {
+		return errors.ErrorCode_CacheError.WithError(err), nil
+	}
+
+	s.markUserActive(ctx, types.ID(req.Uid))
+
+	return errors.ErrorOK(), nil
+}
+
+// RefreshFriendRecommendations recomputes and stores recommendations of given uid.
+// Candidates are friends of friends, members of shared groups and recently viewed users,
+// ranked by data.FriendRecommendation.CalculateScore.
+func (s *FriendService) RefreshFriendRecommendations(ctx context.Context, uid types.ID) error {
+	mutual, err := s.friendRecommendationDao.CountMutualFriendCandidates(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	shared, err := s.friendRecommendationDao.CountSharedGroupCandidates(ctx, uid, data.RecommendMaxGroupSize)
+	if err != nil {
+		return err
+	}
+
+	viewed, err := s.friendRecommendationDao.ListRecentViewedUIDs(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	excludedList, err := s.friendRecommendationDao.ListExcludedUIDs(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	dismissed, err := s.friendRecommendationDao.ListDismissedTargetUIDs(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	excluded := util.NewSet[types.ID]()
+	excluded.Add(uid)
+	for _, id := range excludedList {
+		excluded.Add(id)
+	}
+	for _, id := range dismissed {
+		excluded.Add(id)
+	}
+
+	candidates := make(map[types.ID]*data.FriendRecommendation)
+	getCandidate := func(id types.ID) *data.FriendRecommendation {
+		r, ok := candidates[id]
+		if !ok {
+			r = &data.FriendRecommendation{
+				UID:       uid,
+				TargetUID: id,
+			}
+			candidates[id] = r
+		}
+		return r
+	}
+
+	for id, cnt := range mutual {
+		getCandidate(id).MutualFriendCount = cnt
+	}
+
+	for id, cnt := range shared {
+		getCandidate(id).SharedGroupCount = cnt
+	}
+
+	for _, id := range viewed {
+		getCandidate(id).Viewed = true
+	}
+
+	list := make([]*data.FriendRecommendation, 0, len(candidates))
+	for id, r := range candidates {
+		if excluded.Contains(id) {
+			continue
+		}
+
+		r.CalculateScore()
+		list = append(list, r)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score == list[j].Score {
+			return list[i].TargetUID < list[j].TargetUID
+		}
+		return list[i].Score > list[j].Score
+	})
+
+	if len(list) > data.RecommendMaxCount {
+		list = list[:data.RecommendMaxCount]
+	}
+
+	return db.Transaction(ctx, func(ctx2 context.Context) error {
+		return s.friendRecommendationDao.ReplaceRecommendations(ctx2, uid, list)
+	})
+}
+
 /*
 * handle friend send message ability
  */
@@ -564,20 +1330,30 @@ func (s *FriendService) CheckSendMessageAbility(ctx context.Context, req *friend
 	}
 
 	if req.SessionType == messagev1.SessionType_GroupChat {
-		ok, err := GetGroupService().isInGroup(ctx, to, from)
+		e, muteUntil, err := GetGroupService().checkGroupSendAbility(ctx, to, from)
 		if err != nil {
 			return nil, err
 		}
 
-		if !ok {
-			rsp.Error = errors.ErrorCode_RelationNotExist.Err2()
+		if !e.Success() {
+			rsp.Error = e
+			rsp.MuteUntil = muteUntil
 			return rsp, nil
 		}
 	}
 
 	// todo: check whether user subscribed if session type is channel.
 
+	s.markUserActive(ctx, from)
 	sid := util.Session(req.SessionType, from, to)
 	rsp.SessionId = &sid
 	return rsp, nil
 }
+
+// markUserActive marks uid active so its friend recommendations keep refreshed.
+// It is best effort and never fails the caller.
+func (s *FriendService) markUserActive(ctx context.Context, uid types.ID) {
+	if err := s.friendRecommendationDao.MarkUserActive(ctx, uid); err != nil {
+		log.Error("mark user active error", "uid", uid, "err", err)
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-goim/api/errors"
+	eventv1 "github.com/go-goim/api/user/event/v1"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/event"
+)
+
+// PublishGroupAnnouncement publishes a new announcement of group, only owner and admins can publish.
+func (s *GroupService) PublishGroupAnnouncement(ctx context.Context, req *grouppb.PublishGroupAnnouncementRequest) (
+	*grouppb.PublishGroupAnnouncementResponse, error) {
+	rsp := &grouppb.PublishGroupAnnouncementResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	var (
+		title   = strings.TrimSpace(req.Title)
+		content = strings.TrimSpace(req.Content)
+	)
+
+	if content == "" || utf8.RuneCountInString(title) > data.MaxGroupAnnouncementTitleLength ||
+		utf8.RuneCountInString(content) > data.MaxGroupAnnouncementContentLength {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	operator, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionEditInfo)
+	if e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	a := &data.GroupAnnouncement{
+		GID:       group.GID,
+		AuthorUID: operator.UID,
+		Title:     title,
+		Content:   content,
+		Pinned:    req.Pinned,
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		if err1 := s.groupAnnouncementDao.CreateGroupAnnouncement(ctx2, a); err1 != nil {
+			return err1
+		}
+
+		ge := event.NewGroupEvent(eventv1.GroupEventType_GROUP_ANNOUNCEMENT_PUBLISHED, group.GID, operator.UID)
+		return s.publisher.EnqueueGroupEvent(ctx2, ge)
+	})
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	rsp.Announcement = a.ToProto(true)
+	return rsp, nil
+}
+
+// SetGroupAnnouncementPinned pins or unpins announcement, only owner and admins can pin.
+func (s *GroupService) SetGroupAnnouncementPinned(ctx context.Context, req *grouppb.SetGroupAnnouncementPinnedRequest) (
+	*errors.Error, error) {
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionEditInfo); e != nil {
+		return e, nil
+	}
+
+	a, err := s.groupAnnouncementDao.GetGroupAnnouncement(ctx, req.AnnouncementId)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if a == nil || a.GID != group.GID {
+		return errors.ErrorCode_GroupAnnouncementNotExist.Err2(), nil
+	}
+
+	if err = s.groupAnnouncementDao.UpdateGroupAnnouncementPinned(ctx, a.ID, req.Pinned); err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// ListGroupAnnouncements list announcement history of group, only members can list announcements.
+// Read count is only returned to owner and admins.
+func (s *GroupService) ListGroupAnnouncements(ctx context.Context, req *grouppb.ListGroupAnnouncementsRequest) (
+	*grouppb.ListGroupAnnouncementsResponse, error) {
+	rsp := &grouppb.ListGroupAnnouncementsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, types.ID(req.Gid), types.ID(req.Uid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if gm == nil {
+		rsp.Error = errors.ErrorCode_NotGroupMember.Err2()
+		return rsp, nil
+	}
+
+	list, err := s.groupAnnouncementDao.ListGroupAnnouncements(ctx, gm.GID, int(req.Page), int(req.PageSize))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	withReadCount := gm.HasPermission(data.GroupPermissionEditInfo)
+	for _, a := range list {
+		rsp.Announcements = append(rsp.Announcements, a.ToProto(withReadCount))
+	}
+
+	return rsp, nil
+}
+
+// MarkGroupAnnouncementRead marks announcement as read by member, marking more than once is no-op.
+func (s *GroupService) MarkGroupAnnouncementRead(ctx context.Context, req *grouppb.MarkGroupAnnouncementReadRequest) (
+	*errors.Error, error) {
+	var (
+		gid = types.ID(req.Gid)
+		uid = types.ID(req.Uid)
+	)
+
+	_, ok, err := s.groupMemberDao.IsMemberOfGroup(ctx, gid, uid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if !ok {
+		return errors.ErrorCode_NotGroupMember.Err2(), nil
+	}
+
+	a, err := s.groupAnnouncementDao.GetGroupAnnouncement(ctx, req.AnnouncementId)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if a == nil || a.GID != gid {
+		return errors.ErrorCode_GroupAnnouncementNotExist.Err2(), nil
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		return s.groupAnnouncementDao.MarkGroupAnnouncementRead(ctx2, a.ID, uid)
+	})
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+	"github.com/go-goim/core/pkg/util"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// BanGroupMembers bans users from group for req.DurationSeconds, or permanently if duration is 0.
+// Users in group are removed at the same time, and operator must have higher rank than them.
+func (s *GroupService) BanGroupMembers(ctx context.Context, req *grouppb.BanGroupMembersRequest) (
+	*grouppb.ChangeGroupMemberResponse, error) {
+	rsp := &grouppb.ChangeGroupMemberResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if len(req.Uids) == 0 || req.DurationSeconds < 0 || len(req.Reason) > data.MaxGroupBanReasonLength {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	var (
+		gid         = types.ID(req.Gid)
+		operatorUID = types.ID(req.OperatorUid)
+		uids        = make([]types.ID, 0, len(req.Uids))
+		uidSet      = util.NewSet[types.ID]()
+	)
+
+	for _, uid := range req.Uids {
+		id := types.ID(uid)
+		if !uidSet.Contains(id) {
+			uidSet.Add(id)
+			uids = append(uids, id)
+		}
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	operator, e := s.checkPermission(ctx, group, operatorUID, data.GroupPermissionRemoveMember)
+	if e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	gmList, err := s.groupMemberDao.ListGroupMembersByUIDs(ctx, group.GID, uids)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	needRemoveUIDs := make([]types.ID, 0, len(gmList))
+	for _, gm := range gmList {
+		if !operator.CanManage(gm) {
+			rsp.Error = errors.ErrorCode_GroupPermissionDenied.Err2()
+			return rsp, nil
+		}
+
+		needRemoveUIDs = append(needRemoveUIDs, gm.UID)
+	}
+
+	var expireAt int64
+	if req.DurationSeconds > 0 {
+		expireAt = time.Now().Unix() + req.DurationSeconds
+	}
+
+	bans := make([]*data.GroupBan, len(uids))
+	for i, uid := range uids {
+		bans[i] = &data.GroupBan{
+			GID:         group.GID,
+			UID:         uid,
+			OperatorUID: operatorUID,
+			Reason:      req.Reason,
+			ExpireAt:    expireAt,
+		}
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		if err1 := s.groupBanDao.UpsertGroupBans(ctx2, bans...); err1 != nil {
+			return err1
+		}
+
+		if len(needRemoveUIDs) == 0 {
+			return nil
+		}
+
+		return s.removeGroupMembers(ctx2, group, needRemoveUIDs)
+	})
+	if err != nil {
+		rsp.Error = txError(err)
+		return rsp, nil
+	}
+
+	s.setNonMembersCache(ctx, group.GID, needRemoveUIDs...)
+
+	rsp.Count = int32(len(needRemoveUIDs))
+	return rsp, nil
+}
+
+// UnbanGroupMember removes ban of user, user is not added back to group.
+func (s *GroupService) UnbanGroupMember(ctx context.Context, req *grouppb.UnbanGroupMemberRequest) (
+	*errors.Error, error) {
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionRemoveMember); e != nil {
+		return e, nil
+	}
+
+	if err = s.groupBanDao.DeleteGroupBan(ctx, group.GID, types.ID(req.Uid)); err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// ListGroupBans list active bans of group, only owner and admins can list bans.
+func (s *GroupService) ListGroupBans(ctx context.Context, req *grouppb.ListGroupBansRequest) (
+	*grouppb.ListGroupBansResponse, error) {
+	rsp := &grouppb.ListGroupBansResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionRemoveMember); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	list, err := s.groupBanDao.ListGroupBans(ctx, group.GID, int(req.Page), int(req.PageSize))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	for _, b := range list {
+		rsp.Bans = append(rsp.Bans, b.ToProto())
+	}
+
+	return rsp, nil
+}
+
+// checkNotBanned returns errors.ErrorCode_GroupMemberBanned if any of given uids is banned by group.
+func (s *GroupService) checkNotBanned(ctx context.Context, gid types.ID, uids ...types.ID) *errors.Error {
+	banned, err := s.groupBanDao.ListBannedUIDs(ctx, gid, uids)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	if len(banned) > 0 {
+		return errors.ErrorCode_GroupMemberBanned.Err2()
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/api/errors"
+	eventv1 "github.com/go-goim/api/user/event/v1"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/event"
+)
+
+var (
+	groupRestoreWindow time.Duration
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&groupRestoreWindow, "group-restore-window", 7*24*time.Hour,
+		"duration within which dissolved group can be restored by owner")
+}
+
+// dissolveGroup marks group as dissolved and archives all members in one transaction.
+func (s *GroupService) dissolveGroup(ctx context.Context, group *data.Group, operatorUID types.ID) error {
+	err := db.Transaction(ctx, func(ctx2 context.Context) error {
+		ok, err := s.groupDao.DissolveGroup(ctx2, group.GID)
+		if err != nil {
+			return err
+		}
+
+		// dissolved concurrently
+		if !ok {
+			return errors.ErrorCode_GroupNotExist.Err2()
+		}
+
+		if _, err = s.groupMemberDao.ArchiveGroupMembers(ctx2, group.GID); err != nil {
+			return err
+		}
+
+		e := event.NewGroupEvent(eventv1.GroupEventType_GROUP_DISSOLVED, group.GID, operatorUID)
+		return s.publisher.EnqueueGroupEvent(ctx2, e)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.groupDao.InvalidGroupCache(ctx, group.GID)
+	return nil
+}
+
+// RestoreGroup restores dissolved group with archived members, only owner can restore group within restore window.
+func (s *GroupService) RestoreGroup(ctx context.Context, req *grouppb.RestoreGroupRequest) (
+	*grouppb.RestoreGroupResponse, error) {
+	rsp := &grouppb.RestoreGroupResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	group, err := s.groupDao.GetDissolvedGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if group.OwnerUID.Int64() != req.OwnerUid {
+		rsp.Error = errors.ErrorCode_NotGroupOwner.Err2()
+		return rsp, nil
+	}
+
+	if !group.CanRestore(time.Now().Unix(), int64(groupRestoreWindow.Seconds())) {
+		rsp.Error = errors.ErrorCode_GroupRestoreExpired.Err2()
+		return rsp, nil
+	}
+
+	if e := s.checkOwnedGroupLimit(ctx, group.OwnerUID, group.Tier); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		count, err1 := s.groupMemberDao.RestoreArchivedGroupMembers(ctx2, group.GID)
+		if err1 != nil {
+			return err1
+		}
+
+		ok, err1 := s.groupDao.RestoreGroup(ctx2, group.GID, int(count))
+		if err1 != nil {
+			return err1
+		}
+
+		// restored concurrently
+		if !ok {
+			return errors.ErrorCode_GroupNotExist.Err2()
+		}
+
+		e := event.NewGroupEvent(eventv1.GroupEventType_GROUP_RESTORED, group.GID, group.OwnerUID)
+		return s.publisher.EnqueueGroupEvent(ctx2, e)
+	})
+	if err != nil {
+		rsp.Error = txError(err)
+		return rsp, nil
+	}
+
+	// non-member marks cached during dissolution are stale now.
+	s.purgeMembersCache(ctx, group.GID)
+
+	group, err = s.groupDao.GetGroupByGID(ctx, group.GID)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group != nil {
+		rsp.Group = group.ToProto()
+	}
+
+	return rsp, nil
+}
+
+// PurgeDissolvedGroups deletes archived members of groups whose restore window passed,
+// group rows are kept as tombstone. It returns count of purged groups.
+func (s *GroupService) PurgeDissolvedGroups(ctx context.Context, limit int) (int, error) {
+	before := time.Now().Add(-groupRestoreWindow).Unix()
+	gids, err := s.groupMemberDao.ListExpiredArchivedGIDs(ctx, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, gid := range gids {
+		if err = s.groupMemberDao.DeleteArchivedGroupMembers(ctx, gid); err != nil {
+			log.Error("delete archived group members error", "gid", gid, "err", err)
+			continue
+		}
+
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// CreateGroupInvite creates shareable invite token of group, only owner and admins can create invites.
+// ExpireSeconds and MaxUses are optional, zero means never expire and unlimited uses.
+func (s *GroupService) CreateGroupInvite(ctx context.Context, req *grouppb.CreateGroupInviteRequest) (
+	*grouppb.CreateGroupInviteResponse, error) {
+	rsp := &grouppb.CreateGroupInviteResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if req.ExpireSeconds < 0 || req.MaxUses < 0 {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	operator, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionAddMember)
+	if e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	token, err := newGroupInviteToken()
+	if err != nil {
+		rsp.Error = errors.ErrorCode_InternalError.WithError(err)
+		return rsp, nil
+	}
+
+	invite := &data.GroupInvite{
+		GID:        group.GID,
+		Token:      token,
+		InviterUID: operator.UID,
+		MaxUses:    int(req.MaxUses),
+	}
+
+	if req.ExpireSeconds > 0 {
+		invite.ExpireAt = time.Now().Unix() + req.ExpireSeconds
+	}
+
+	if err = s.groupInviteDao.CreateGroupInvite(ctx, invite); err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	rsp.Invite = invite.ToProto()
+	return rsp, nil
+}
+
+func newGroupInviteToken() (string, error) {
+	b := make([]byte, data.GroupInviteTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// ListGroupInvites list usable invites of group, only owner and admins can list invites.
+func (s *GroupService) ListGroupInvites(ctx context.Context, req *grouppb.ListGroupInvitesRequest) (
+	*grouppb.ListGroupInvitesResponse, error) {
+	rsp := &grouppb.ListGroupInvitesResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionAddMember); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	list, err := s.groupInviteDao.ListGroupInvites(ctx, group.GID)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	for _, invite := range list {
+		rsp.Invites = append(rsp.Invites, invite.ToProto())
+	}
+
+	return rsp, nil
+}
+
+// RevokeGroupInvite revokes invite of group, only owner and admins can revoke invites.
+func (s *GroupService) RevokeGroupInvite(ctx context.Context, req *grouppb.RevokeGroupInviteRequest) (
+	*errors.Error, error) {
+	invite, err := s.groupInviteDao.GetGroupInviteByToken(ctx, req.Token)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if invite == nil || invite.GID.Int64() != req.Gid {
+		return errors.ErrorCode_GroupInviteNotExist.Err2(), nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, invite.GID)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionAddMember); e != nil {
+		return e, nil
+	}
+
+	if invite.Revoked {
+		return errors.ErrorOK(), nil
+	}
+
+	if err = s.groupInviteDao.RevokeGroupInvite(ctx, invite); err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// JoinGroupByInvite joins group with invite token, join policy of group is bypassed.
+// Invite becomes invalid once inviter is no longer able to add members.
+func (s *GroupService) JoinGroupByInvite(ctx context.Context, req *grouppb.JoinGroupByInviteRequest) (
+	*grouppb.JoinGroupByInviteResponse, error) {
+	rsp := &grouppb.JoinGroupByInviteResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	uid := types.ID(req.Uid)
+
+	invite, err := s.groupInviteDao.GetGroupInviteByToken(ctx, req.Token)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if invite == nil {
+		rsp.Error = errors.ErrorCode_GroupInviteNotExist.Err2()
+		return rsp, nil
+	}
+
+	if !invite.IsUsable(time.Now().Unix()) {
+		rsp.Error = errors.ErrorCode_GroupInviteExpired.Err2()
+		return rsp, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, invite.GID)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, invite.InviterUID, data.GroupPermissionAddMember); e != nil {
+		rsp.Error = errors.ErrorCode_GroupInviteExpired.Err2()
+		return rsp, nil
+	}
+
+	user, err := s.userDao.GetUserByUID(ctx, uid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if user == nil || user.IsDeleted() {
+		rsp.Error = errors.ErrorCode_UserNotExist.Err2()
+		return rsp, nil
+	}
+
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, uid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if gm != nil {
+		rsp.Error = errors.ErrorCode_AlreadyGroupMember.Err2()
+		return rsp, nil
+	}
+
+	if e := s.checkNotBanned(ctx, group.GID, uid); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	var gmList []*data.GroupMember
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		ok, err1 := s.groupInviteDao.IncrGroupInviteUsedCount(ctx2, invite)
+		if err1 != nil {
+			return err1
+		}
+
+		// revoked, expired or used up concurrently
+		if !ok {
+			return errors.ErrorCode_GroupInviteExpired.Err2()
+		}
+
+		gmList, err1 = s.addGroupMembers(ctx2, group, invite.InviterUID, []types.ID{uid})
+		return err1
+	})
+	if err != nil {
+		rsp.Error = txError(err)
+		return rsp, nil
+	}
+
+	s.setMembersCache(ctx, group.GID, gmList...)
+
+	rsp.Group = group.ToProto()
+	return rsp, nil
+}
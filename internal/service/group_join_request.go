@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/types"
+	"github.com/go-goim/core/pkg/util"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// ApplyJoinGroup applies to join a group according to join policy of the group.
+// User joins directly if the group is open or the answer is correct,
+// and a pending join request is created if the group requires approval.
+func (s *GroupService) ApplyJoinGroup(ctx context.Context, req *grouppb.ApplyJoinGroupRequest) (
+	*grouppb.ApplyJoinGroupResponse, error) {
+	rsp := &grouppb.ApplyJoinGroupResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if len(req.GetMessage()) > data.MaxGroupJoinRequestMessageLength {
+		rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("message too long")
+		return rsp, nil
+	}
+
+	var (
+		gid = types.ID(req.Gid)
+		uid = types.ID(req.Uid)
+	)
+
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	user, err := s.userDao.GetUserByUID(ctx, uid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if user == nil || user.IsDeleted() {
+		rsp.Error = errors.ErrorCode_UserNotExist.Err2()
+		return rsp, nil
+	}
+
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, uid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if gm != nil {
+		rsp.Error = errors.ErrorCode_AlreadyGroupMember.Err2()
+		return rsp, nil
+	}
+
+	if e := s.checkNotBanned(ctx, group.GID, uid); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	switch group.JoinPolicy {
+	case grouppb.GroupJoinPolicy_Open:
+	case grouppb.GroupJoinPolicy_AnswerQuestion:
+		if !group.CheckJoinAnswer(req.GetAnswer()) {
+			rsp.Error = errors.ErrorCode_GroupJoinAnswerWrong.Err2()
+			return rsp, nil
+		}
+	case grouppb.GroupJoinPolicy_Approval:
+		r, err1 := s.applyJoinGroupRequest(ctx, group, uid, req.GetMessage())
+		if err1 != nil {
+			rsp.Error = txError(err1)
+			return rsp, nil
+		}
+
+		rsp.JoinRequest = r.ToProto()
+		return rsp, nil
+	default:
+		// invite only, unknown policy is not allowed either.
+		rsp.Error = errors.ErrorCode_GroupJoinNotAllowed.Err2()
+		return rsp, nil
+	}
+
+	var gmList []*data.GroupMember
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		var err1 error
+		gmList, err1 = s.addGroupMembers(ctx2, group, uid, []types.ID{uid})
+		return err1
+	})
+	if err != nil {
+		rsp.Error = txError(err)
+		return rsp, nil
+	}
+
+	s.setMembersCache(ctx, group.GID, gmList...)
+
+	rsp.Joined = true
+	return rsp, nil
+}
+
+// applyJoinGroupRequest creates pending join request, the existing one is returned if already applied.
+func (s *GroupService) applyJoinGroupRequest(ctx context.Context, group *data.Group, uid types.ID, message string) (
+	*data.GroupJoinRequest, error) {
+	r := &data.GroupJoinRequest{
+		GID:     group.GID,
+		UID:     uid,
+		Message: message,
+		Status:  grouppb.GroupJoinRequest_StatusPending,
+	}
+
+	created, err := s.groupJoinRequestDao.CreatePendingGroupJoinRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		return r, nil
+	}
+
+	r, err = s.groupJoinRequestDao.GetPendingGroupJoinRequest(ctx, group.GID, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	// existing request is handled or withdrawn concurrently.
+	if r == nil {
+		return nil, errors.ErrorCode_GroupJoinRequestStatusError.Err2()
+	}
+
+	return r, nil
+}
+
+// ListGroupJoinRequests list pending join requests of group, only owner and admins can list them.
+func (s *GroupService) ListGroupJoinRequests(ctx context.Context, req *grouppb.ListGroupJoinRequestsRequest) (
+	*grouppb.ListGroupJoinRequestsResponse, error) {
+	rsp := &grouppb.ListGroupJoinRequestsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionAddMember); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	list, err := s.groupJoinRequestDao.ListPendingGroupJoinRequests(ctx, group.GID, int(req.Page), int(req.PageSize))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	for _, r := range list {
+		rsp.JoinRequests = append(rsp.JoinRequests, r.ToProto())
+	}
+
+	return rsp, nil
+}
+
+// HandleGroupJoinRequests approves or rejects pending join requests of group in bulk.
+// Requests not pending are ignored, approval fails as a whole if the group has no room for all applicants.
+func (s *GroupService) HandleGroupJoinRequests(ctx context.Context, req *grouppb.HandleGroupJoinRequestsRequest) (
+	*grouppb.HandleGroupJoinRequestsResponse, error) {
+	rsp := &grouppb.HandleGroupJoinRequestsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if len(req.RequestIds) == 0 || len(req.RequestIds) > data.MaxHandleGroupJoinRequestCount {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	var (
+		gid         = types.ID(req.Gid)
+		operatorUID = types.ID(req.OperatorUid)
+	)
+
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	if _, e := s.checkPermission(ctx, group, operatorUID, data.GroupPermissionAddMember); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	switch req.Action {
+	case grouppb.HandleGroupJoinRequestAction_Reject:
+		count, err1 := s.groupJoinRequestDao.UpdateGroupJoinRequestsStatus(ctx, group.GID, req.RequestIds,
+			grouppb.GroupJoinRequest_StatusRejected, operatorUID)
+		if err1 != nil {
+			rsp.Error = errors.ErrorCode_DBError.WithError(err1)
+			return rsp, nil
+		}
+
+		rsp.Count = int32(count)
+		return rsp, nil
+	case grouppb.HandleGroupJoinRequestAction_Approve:
+		count, gmList, err1 := s.approveGroupJoinRequests(ctx, group, operatorUID, req.RequestIds)
+		if err1 != nil {
+			rsp.Error = txError(err1)
+			return rsp, nil
+		}
+
+		s.setMembersCache(ctx, group.GID, gmList...)
+
+		rsp.Count = int32(count)
+		return rsp, nil
+	default:
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+}
+
+// approveGroupJoinRequests approves pending join requests and adds applicants to group in one transaction.
+// Applicants already in group are not added again but their requests are still approved,
+// and requests of banned applicants are rejected. Count of approved requests is returned.
+func (s *GroupService) approveGroupJoinRequests(ctx context.Context, group *data.Group, operatorUID types.ID,
+	ids []uint64) (count int64, gmList []*data.GroupMember, err error) {
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		list, err1 := s.groupJoinRequestDao.ListPendingGroupJoinRequestsForUpdate(ctx2, group.GID, ids)
+		if err1 != nil {
+			return err1
+		}
+
+		if len(list) == 0 {
+			return nil
+		}
+
+		applicants := make([]types.ID, len(list))
+		for i, r := range list {
+			applicants[i] = r.UID
+		}
+
+		// requests of banned applicants are rejected instead.
+		bannedUIDs, err1 := s.groupBanDao.ListBannedUIDs(ctx2, group.GID, applicants)
+		if err1 != nil {
+			return err1
+		}
+
+		bannedSet := util.NewSet[types.ID]()
+		for _, uid := range bannedUIDs {
+			bannedSet.Add(uid)
+		}
+
+		var (
+			approveIDs = make([]uint64, 0, len(list))
+			rejectIDs  = make([]uint64, 0)
+			uids       = make([]types.ID, 0, len(list))
+			uidSet     = util.NewSet[types.ID]()
+		)
+		for _, r := range list {
+			if bannedSet.Contains(r.UID) {
+				rejectIDs = append(rejectIDs, r.ID)
+				continue
+			}
+
+			approveIDs = append(approveIDs, r.ID)
+			if !uidSet.Contains(r.UID) {
+				uidSet.Add(r.UID)
+				uids = append(uids, r.UID)
+			}
+		}
+
+		_, err1 = s.groupJoinRequestDao.UpdateGroupJoinRequestsStatus(ctx2, group.GID, rejectIDs,
+			grouppb.GroupJoinRequest_StatusRejected, operatorUID)
+		if err1 != nil {
+			return err1
+		}
+
+		inGroupUIDs, err1 := s.groupMemberDao.ListInGroupUIDs(ctx2, group.GID, uids)
+		if err1 != nil {
+			return err1
+		}
+
+		inGroupSet := util.NewSet[types.ID]()
+		for _, uid := range inGroupUIDs {
+			inGroupSet.Add(uid)
+		}
+
+		newUIDs := make([]types.ID, 0, len(uids))
+		for _, uid := range uids {
+			if !inGroupSet.Contains(uid) {
+				newUIDs = append(newUIDs, uid)
+			}
+		}
+
+		if len(newUIDs) > 0 {
+			gmList, err1 = s.addGroupMembers(ctx2, group, operatorUID, newUIDs)
+			if err1 != nil {
+				return err1
+			}
+		}
+
+		count, err1 = s.groupJoinRequestDao.UpdateGroupJoinRequestsStatus(ctx2, group.GID, approveIDs,
+			grouppb.GroupJoinRequest_StatusApproved, operatorUID)
+		return err1
+	})
+
+	return count, gmList, err
+}
+
+// WithdrawGroupJoinRequest withdraws pending join request by applicant.
+func (s *GroupService) WithdrawGroupJoinRequest(ctx context.Context, req *grouppb.WithdrawGroupJoinRequestRequest) (
+	*errors.Error, error) {
+	r, err := s.groupJoinRequestDao.GetGroupJoinRequestByID(ctx, req.Id)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if r == nil || r.UID.Int64() != req.Uid {
+		return errors.ErrorCode_GroupJoinRequestNotExist.Err2(), nil
+	}
+
+	if !r.IsPending() {
+		return errors.ErrorCode_GroupJoinRequestStatusError.Err2(), nil
+	}
+
+	count, err := s.groupJoinRequestDao.UpdateGroupJoinRequestsStatus(ctx, r.GID, []uint64{r.ID},
+		grouppb.GroupJoinRequest_StatusWithdrawn, r.UID)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	// handled by owner or admins concurrently
+	if count == 0 {
+		return errors.ErrorCode_GroupJoinRequestStatusError.Err2(), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// ReconcileGroupMemberCounts recomputes member count of groups from group_member table and reports drifted groups.
+// Groups in req.Gids are checked if given, otherwise groups are scanned by cursor.
+// Drifted counts are fixed unless req.DryRun is set. Only system admins can reconcile member counts.
+func (s *GroupService) ReconcileGroupMemberCounts(ctx context.Context, req *grouppb.ReconcileGroupMemberCountsRequest) (
+	*grouppb.ReconcileGroupMemberCountsResponse, error) {
+	rsp := &grouppb.ReconcileGroupMemberCountsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if !isSystemAdmin(types.ID(req.OperatorUid)) {
+		rsp.Error = errors.ErrorCode_GroupPermissionDenied.Err2()
+		return rsp, nil
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 || limit > data.MaxPageSize {
+		limit = data.DefaultPageSize
+	}
+
+	var (
+		groups []*data.Group
+		err    error
+	)
+	if len(req.Gids) > 0 {
+		if len(req.Gids) > data.MaxPageSize {
+			rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+			return rsp, nil
+		}
+
+		gids := make([]types.ID, len(req.Gids))
+		for i, gid := range req.Gids {
+			gids[i] = types.ID(gid)
+		}
+
+		groups, err = s.groupDao.ListGroups(ctx, gids)
+	} else {
+		groups, err = s.groupDao.ListGroupsAfter(ctx, req.Cursor, limit)
+		if err == nil && len(groups) == limit {
+			rsp.HasMore = true
+			rsp.NextCursor = groups[len(groups)-1].ID
+		}
+	}
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	rsp.Drifts, err = s.reconcileGroupMemberCounts(ctx, groups, req.DryRun)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	return rsp, nil
+}
+
+// ReconcileMemberCounts reconciles member count of at most limit groups whose id greater than afterID.
+// The id of last scanned group is returned as next cursor, 0 if all groups are scanned.
+func (s *GroupService) ReconcileMemberCounts(ctx context.Context, afterID uint64, limit int, dryRun bool) (
+	[]*grouppb.GroupMemberCountDrift, uint64, error) {
+	groups, err := s.groupDao.ListGroupsAfter(ctx, afterID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	drifts, err := s.reconcileGroupMemberCounts(ctx, groups, dryRun)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(groups) < limit {
+		return drifts, 0, nil
+	}
+
+	return drifts, groups[len(groups)-1].ID, nil
+}
+
+// reconcileGroupMemberCounts compares member count of groups with count of member rows and fixes drifted ones.
+// Fixing is skipped if count changed concurrently, it will be checked again next time.
+func (s *GroupService) reconcileGroupMemberCounts(ctx context.Context, groups []*data.Group, dryRun bool) (
+	[]*grouppb.GroupMemberCountDrift, error) {
+	drifts := make([]*grouppb.GroupMemberCountDrift, 0)
+	if len(groups) == 0 {
+		return drifts, nil
+	}
+
+	gids := make([]types.ID, len(groups))
+	for i, g := range groups {
+		gids[i] = g.GID
+	}
+
+	counts, err := s.groupMemberDao.CountGroupMembersByGIDs(ctx, gids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		actual := counts[g.GID]
+		if actual == g.MemberCount {
+			continue
+		}
+
+		drift := &grouppb.GroupMemberCountDrift{
+			Gid:      g.GID.Int64(),
+			Recorded: int32(g.MemberCount),
+			Actual:   int32(actual),
+		}
+		drifts = append(drifts, drift)
+
+		if dryRun {
+			continue
+		}
+
+		drift.Fixed, err = s.groupDao.FixGroupMemberCount(ctx, g.GID, g.MemberCount, actual)
+		if err != nil {
+			log.Error("fix group member count error", "gid", g.GID, "err", err)
+		}
+	}
+
+	return drifts, nil
+}
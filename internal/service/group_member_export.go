@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// ExportGroupMemberUIDs pages uids of all members by cursor, which is used by fan-out services.
+// Uids of super group are read from member uid set in cache, other groups are read from db.
+// The cursor is id of last member of previous page, so both sources share the same cursor.
+func (s *GroupService) ExportGroupMemberUIDs(ctx context.Context, req *grouppb.ExportGroupMemberUIDsRequest) (
+	*grouppb.ExportGroupMemberUIDsResponse, error) {
+	rsp := &grouppb.ExportGroupMemberUIDsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 || limit > data.MaxExportGroupMemberUIDCount {
+		limit = data.MaxExportGroupMemberUIDCount
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	// load one more member to know whether there is next page.
+	var gmList []*data.GroupMember
+	if s.loadGroupMemberUIDSet(ctx, group) {
+		gmList, err = s.groupMemberDao.ListGroupMemberUIDsFromSet(ctx, group.GID, req.Cursor, limit+1)
+		if err != nil {
+			log.Error("list member uids from set error", "gid", group.GID, "err", err)
+			gmList = nil
+		}
+	}
+
+	if gmList == nil {
+		gmList, err = s.groupMemberDao.ListGroupMemberUIDsAfter(ctx, group.GID, req.Cursor, limit+1)
+		if err != nil {
+			rsp.Error = errors.ErrorCode_DBError.WithError(err)
+			return rsp, nil
+		}
+	}
+
+	if len(gmList) > limit {
+		gmList = gmList[:limit]
+		rsp.HasMore = true
+	}
+
+	rsp.Uids = make([]int64, len(gmList))
+	for i, gm := range gmList {
+		rsp.Uids[i] = gm.UID.Int64()
+	}
+
+	if len(gmList) > 0 {
+		rsp.NextCursor = gmList[len(gmList)-1].ID
+	}
+
+	return rsp, nil
+}
+
+// CheckGroupMembers returns uids which are members of group from given uid list.
+// Super group is checked against member uid set, so that the whole member list is never loaded.
+func (s *GroupService) CheckGroupMembers(ctx context.Context, req *grouppb.CheckGroupMembersRequest) (
+	*grouppb.CheckGroupMembersResponse, error) {
+	rsp := &grouppb.CheckGroupMembersResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	if len(req.Uids) > data.MaxExportGroupMemberUIDCount {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	uids := make([]types.ID, len(req.Uids))
+	for i, uid := range req.Uids {
+		uids[i] = types.ID(uid)
+	}
+
+	var inGroupUIDs []types.ID
+	if s.loadGroupMemberUIDSet(ctx, group) {
+		inGroupUIDs, err = s.groupMemberDao.ListInGroupUIDsFromSet(ctx, group.GID, uids)
+		if err != nil {
+			log.Error("check members from set error", "gid", group.GID, "err", err)
+			inGroupUIDs = nil
+		}
+	}
+
+	if inGroupUIDs == nil {
+		inGroupUIDs, err = s.groupMemberDao.ListInGroupUIDs(ctx, group.GID, uids)
+		if err != nil {
+			rsp.Error = errors.ErrorCode_DBError.WithError(err)
+			return rsp, nil
+		}
+	}
+
+	rsp.MemberUids = make([]int64, len(inGroupUIDs))
+	for i, uid := range inGroupUIDs {
+		rsp.MemberUids[i] = uid.Int64()
+	}
+
+	return rsp, nil
+}
+
+// loadGroupMemberUIDSet makes sure member uid set of super group is cached.
+// It returns false for other groups or cache unavailable, then caller should fall back to db.
+func (s *GroupService) loadGroupMemberUIDSet(ctx context.Context, group *data.Group) bool {
+	if !group.IsSuperGroup() {
+		return false
+	}
+
+	loaded, err := s.groupMemberDao.LoadGroupMemberUIDSet(ctx, group.GID)
+	if err != nil {
+		log.Error("load member uid set error", "gid", group.GID, "err", err)
+		return false
+	}
+
+	return loaded
+}
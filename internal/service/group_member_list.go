@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/dao"
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// ListGroupMembers list members of group by cursor, only members of group can list members.
+// Members can be filtered by type and status, and sorted by join time or role.
+// Profiles of members are loaded from user cache if req.WithProfile is set.
+func (s *GroupService) ListGroupMembers(ctx context.Context, req *grouppb.ListGroupMembersRequest) (
+	*grouppb.ListGroupMembersResponse, error) {
+	rsp := &grouppb.ListGroupMembersResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	var (
+		gid = types.ID(req.Gid)
+		uid = types.ID(req.Uid)
+		opt = &dao.ListGroupMembersOption{
+			Types:       req.Types,
+			Statuses:    req.Statuses,
+			OrderByRole: req.OrderBy == grouppb.ListGroupMembersRequest_OrderByRole,
+			Limit:       int(req.Limit),
+		}
+	)
+
+	if opt.Limit <= 0 || opt.Limit > data.MaxPageSize {
+		opt.Limit = data.DefaultPageSize
+	}
+
+	if req.Cursor != "" {
+		if _, err := fmt.Sscanf(req.Cursor, "%d:%d", &opt.AfterRoleOrder, &opt.AfterID); err != nil {
+			rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("invalid cursor")
+			return rsp, nil
+		}
+	}
+
+	_, ok, err := s.groupMemberDao.IsMemberOfGroup(ctx, gid, uid)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if !ok {
+		rsp.Error = errors.ErrorCode_NotGroupMember.Err2()
+		return rsp, nil
+	}
+
+	// load one more member to know whether there is next page.
+	limit := opt.Limit
+	opt.Limit++
+	gmList, err := s.groupMemberDao.ListGroupMembers(ctx, gid, opt)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if len(gmList) > limit {
+		gmList = gmList[:limit]
+		last := gmList[limit-1]
+		rsp.HasMore = true
+		rsp.NextCursor = fmt.Sprintf("%d:%d", last.RoleOrder(), last.ID)
+	}
+
+	rsp.Members = make([]*grouppb.GroupMember, len(gmList))
+	for i, m := range gmList {
+		rsp.Members[i] = m.ToProto()
+		rsp.Members[i].DisplayName = m.Nickname
+	}
+
+	if !req.WithProfile || len(gmList) == 0 {
+		return rsp, nil
+	}
+
+	uids := make([]types.ID, len(gmList))
+	for i, m := range gmList {
+		uids[i] = m.UID
+	}
+
+	users, err := s.userDao.ListUsersWithCache(ctx, uids...)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	userMap := make(map[int64]*data.User, len(users))
+	for _, u := range users {
+		userMap[u.UID.Int64()] = u
+	}
+
+	for i, m := range gmList {
+		if u, ok := userMap[m.UID.Int64()]; ok {
+			rsp.Members[i].User = u.ToProto()
+			rsp.Members[i].DisplayName = m.DisplayName(u.Name)
+		}
+	}
+
+	return rsp, nil
+}
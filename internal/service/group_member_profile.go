@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// SetGroupNickname sets nickname of caller in group, empty nickname clears it.
+func (s *GroupService) SetGroupNickname(ctx context.Context, req *grouppb.SetGroupNicknameRequest) (
+	*errors.Error, error) {
+	nickname := strings.TrimSpace(req.Nickname)
+	if utf8.RuneCountInString(nickname) > data.MaxGroupNicknameLength {
+		return errors.ErrorCode_InvalidParams.WithMessage("nickname too long"), nil
+	}
+
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, types.ID(req.Gid), types.ID(req.Uid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if gm == nil {
+		return errors.ErrorCode_NotGroupMember.Err2(), nil
+	}
+
+	if err = s.groupMemberDao.UpdateGroupMemberNickname(ctx, gm.GID, gm.UID, nickname); err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
+
+// SetGroupMemberTitle sets title of member, empty title clears it.
+// Only owner and admins can set titles, of themselves or members with lower rank.
+func (s *GroupService) SetGroupMemberTitle(ctx context.Context, req *grouppb.SetGroupMemberTitleRequest) (
+	*errors.Error, error) {
+	title := strings.TrimSpace(req.Title)
+	if utf8.RuneCountInString(title) > data.MaxGroupTitleLength {
+		return errors.ErrorCode_InvalidParams.WithMessage("title too long"), nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	operator, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionSetTitle)
+	if e != nil {
+		return e, nil
+	}
+
+	target, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, types.ID(req.Uid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if target == nil {
+		return errors.ErrorCode_NotGroupMember.Err2(), nil
+	}
+
+	if target.UID != operator.UID && !operator.CanManage(target) {
+		return errors.ErrorCode_GroupPermissionDenied.Err2(), nil
+	}
+
+	if err = s.groupMemberDao.UpdateGroupMemberTitle(ctx, group.GID, target.UID, title); err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	return errors.ErrorOK(), nil
+}
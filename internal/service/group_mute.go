@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/log"
+	"github.com/go-goim/core/pkg/types"
+
+	"github.com/go-goim/user-service/internal/data"
+)
+
+// MuteGroupMember mutes member for req.DurationSeconds, or indefinitely if duration is 0.
+// Operator must have mute permission and higher rank than the member.
+func (s *GroupService) MuteGroupMember(ctx context.Context, req *grouppb.MuteGroupMemberRequest) (*errors.Error, error) {
+	if req.DurationSeconds < 0 {
+		return errors.ErrorCode_InvalidParams.Err2(), nil
+	}
+
+	var muteUntil int64
+	if req.DurationSeconds > 0 {
+		muteUntil = time.Now().Unix() + req.DurationSeconds
+	}
+
+	return s.setGroupMemberMute(ctx, req, grouppb.GroupMember_StatusSilent, muteUntil), nil
+}
+
+// UnmuteGroupMember unmutes member, operator must have mute permission and higher rank than the member.
+func (s *GroupService) UnmuteGroupMember(ctx context.Context, req *grouppb.MuteGroupMemberRequest) (
+	*errors.Error, error) {
+	return s.setGroupMemberMute(ctx, req, grouppb.GroupMember_StatusActive, 0), nil
+}
+
+func (s *GroupService) setGroupMemberMute(ctx context.Context, req *grouppb.MuteGroupMemberRequest,
+	status grouppb.GroupMember_Status, muteUntil int64) *errors.Error {
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2()
+	}
+
+	operator, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionMuteMember)
+	if e != nil {
+		return e
+	}
+
+	target, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, types.ID(req.Uid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	if target == nil {
+		return errors.ErrorCode_NotGroupMember.Err2()
+	}
+
+	if !operator.CanManage(target) {
+		return errors.ErrorCode_GroupPermissionDenied.Err2()
+	}
+
+	err = s.groupMemberDao.UpdateGroupMemberMute(ctx, group.GID, target.UID, status, muteUntil)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	target.Status = status
+	target.MuteUntil = muteUntil
+	s.setMembersCache(ctx, group.GID, target)
+
+	return errors.ErrorOK()
+}
+
+// MuteGroup mutes all members of group except owner and admins for req.DurationSeconds,
+// or indefinitely if duration is 0.
+func (s *GroupService) MuteGroup(ctx context.Context, req *grouppb.MuteGroupRequest) (*errors.Error, error) {
+	if req.DurationSeconds < 0 {
+		return errors.ErrorCode_InvalidParams.Err2(), nil
+	}
+
+	var muteUntil int64
+	if req.DurationSeconds > 0 {
+		muteUntil = time.Now().Unix() + req.DurationSeconds
+	}
+
+	return s.setGroupMute(ctx, req, grouppb.GroupStatus_Silent, muteUntil), nil
+}
+
+// UnmuteGroup unmutes group, members muted individually are still muted.
+func (s *GroupService) UnmuteGroup(ctx context.Context, req *grouppb.MuteGroupRequest) (*errors.Error, error) {
+	return s.setGroupMute(ctx, req, grouppb.GroupStatus_Active, 0), nil
+}
+
+func (s *GroupService) setGroupMute(ctx context.Context, req *grouppb.MuteGroupRequest,
+	status grouppb.GroupStatus, muteUntil int64) *errors.Error {
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2()
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OperatorUid), data.GroupPermissionMuteMember); e != nil {
+		return e
+	}
+
+	if err = s.groupDao.UpdateGroupMute(ctx, group.GID, status, muteUntil); err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	s.groupDao.InvalidGroupCache(ctx, group.GID)
+
+	return errors.ErrorOK()
+}
+
+// checkGroupSendAbility checks whether uid can send message to group.
+// Unmute time is returned along with error if uid or the group is muted, 0 means muted indefinitely.
+// Expired mutes found here are cleared, so that they won't be checked again.
+func (s *GroupService) checkGroupSendAbility(ctx context.Context, gid, uid types.ID) (
+	rsp *errors.Error, muteUntil int64, err error) {
+	status, ok, err := s.groupMemberDao.IsMemberOfGroup(ctx, gid, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !ok {
+		return errors.ErrorCode_RelationNotExist.Err2(), 0, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGIDWithCache(ctx, gid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), 0, nil
+	}
+
+	now := time.Now().Unix()
+	groupMuted := group.IsMuted(now)
+	if group.IsSilent() && !groupMuted {
+		s.unmuteExpiredGroup(ctx, group.GID, now)
+	}
+
+	// only status is cached, type and mute time are needed from db.
+	if status != grouppb.GroupMember_StatusActive || groupMuted {
+		var gm *data.GroupMember
+		gm, err = s.groupMemberDao.GetGroupMemberByGIDUID(ctx, gid, uid)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if gm == nil {
+			return errors.ErrorCode_RelationNotExist.Err2(), 0, nil
+		}
+
+		if gm.IsMuted(now) {
+			return errors.ErrorCode_GroupMemberMuted.Err2(), gm.MuteUntil, nil
+		}
+
+		if gm.Status == grouppb.GroupMember_StatusSilent {
+			s.unmuteExpiredMember(ctx, gm, now)
+		}
+
+		// owner and admins are exempted from group mute.
+		if groupMuted && !gm.IsOwner() && !gm.IsAdmin() {
+			return errors.ErrorCode_GroupMuted.Err2(), group.MuteUntil, nil
+		}
+	}
+
+	s.touchGroupActiveAt(ctx, group, now)
+	return errors.ErrorOK(), 0, nil
+}
+
+// touchGroupActiveAt records group as active when message can be sent to it, the active time is only
+// written every data.GroupActiveAtUpdateInterval seconds. Error is only logged.
+func (s *GroupService) touchGroupActiveAt(ctx context.Context, group *data.Group, now int64) {
+	notUpdatedSince := now - data.GroupActiveAtUpdateInterval
+	if group.ActiveAt >= notUpdatedSince {
+		return
+	}
+
+	if err := s.groupDao.TouchGroupActiveAt(ctx, group.GID, now, notUpdatedSince); err != nil {
+		log.Error("update group active time error", "gid", group.GID, "err", err)
+		return
+	}
+
+	// cached active time is stale now, drop it so that group won't be touched again on every message.
+	s.groupDao.InvalidGroupCache(ctx, group.GID)
+}
+
+// ExpireMutes clears expired mutes of members and groups, at most limit rows of each are cleared.
+// It returns count of cleared mutes.
+func (s *GroupService) ExpireMutes(ctx context.Context, limit int) (int, error) {
+	now := time.Now().Unix()
+	gmList, err := s.groupMemberDao.ListExpiredMutedMembers(ctx, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, gm := range gmList {
+		if s.unmuteExpiredMember(ctx, gm, now) {
+			count++
+		}
+	}
+
+	groups, err := s.groupDao.ListExpiredMutedGroups(ctx, now, limit)
+	if err != nil {
+		return count, err
+	}
+
+	for _, g := range groups {
+		if s.unmuteExpiredGroup(ctx, g.GID, now) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// unmuteExpiredMember unmutes member whose mute expired and refreshes cache, error is only logged.
+func (s *GroupService) unmuteExpiredMember(ctx context.Context, gm *data.GroupMember, now int64) bool {
+	ok, err := s.groupMemberDao.UnmuteExpiredGroupMember(ctx, gm.GID, gm.UID, now)
+	if err != nil {
+		log.Error("unmute expired group member error", "gid", gm.GID, "uid", gm.UID, "err", err)
+		return false
+	}
+
+	if !ok {
+		return false
+	}
+
+	gm.Status = grouppb.GroupMember_StatusActive
+	gm.MuteUntil = 0
+	s.setMembersCache(ctx, gm.GID, gm)
+	return true
+}
+
+// unmuteExpiredGroup unmutes group whose mute expired, error is only logged.
+func (s *GroupService) unmuteExpiredGroup(ctx context.Context, gid types.ID, now int64) bool {
+	ok, err := s.groupDao.UnmuteExpiredGroup(ctx, gid, now)
+	if err != nil {
+		log.Error("unmute expired group error", "gid", gid, "err", err)
+		return false
+	}
+
+	if ok {
+		s.groupDao.InvalidGroupCache(ctx, gid)
+	}
+
+	return ok
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/cmd"
+
+	"github.com/go-goim/user-service/internal/dao"
+)
+
+var (
+	groupSearchActiveWindow time.Duration
+)
+
+func init() {
+	cmd.GlobalFlagSet.DurationVar(&groupSearchActiveWindow, "group-search-active-window", 7*24*time.Hour,
+		"groups with message sent within this duration are ranked first in search results")
+}
+
+// maxGroupSearchKeywordLength is max length of search keyword in runes.
+const maxGroupSearchKeywordLength = 64
+
+// SearchGroups searches public groups by keyword over name, description and tags, or by a single tag.
+// Dissolved and full groups are excluded. Recently active groups are ranked first, then by member count.
+func (s *GroupService) SearchGroups(ctx context.Context, req *grouppb.SearchGroupsRequest) (
+	*grouppb.SearchGroupsResponse, error) {
+	rsp := &grouppb.SearchGroupsResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	opt := &dao.SearchGroupsOption{
+		Keyword:     strings.TrimSpace(req.Keyword),
+		Tag:         strings.ToLower(strings.TrimSpace(req.Tag)),
+		ActiveAfter: time.Now().Add(-groupSearchActiveWindow).Unix(),
+		Page:        int(req.Page),
+		PageSize:    int(req.PageSize),
+	}
+
+	if utf8.RuneCountInString(opt.Keyword) > maxGroupSearchKeywordLength {
+		rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("keyword too long")
+		return rsp, nil
+	}
+
+	groups, err := s.groupDao.SearchGroups(ctx, opt)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	rsp.Groups = make([]*grouppb.Group, len(groups))
+	for i, g := range groups {
+		rsp.Groups[i] = g.ToProto()
+	}
+
+	return rsp, nil
+}
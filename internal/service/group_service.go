@@ -3,20 +3,38 @@ package service
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/go-goim/api/errors"
+	eventv1 "github.com/go-goim/api/user/event/v1"
 	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/cmd"
 	"github.com/go-goim/core/pkg/db"
+	"github.com/go-goim/core/pkg/log"
 	"github.com/go-goim/core/pkg/types"
 	"github.com/go-goim/core/pkg/util"
 	"github.com/go-goim/user-service/internal/dao"
 	"github.com/go-goim/user-service/internal/data"
+	"github.com/go-goim/user-service/internal/event"
 )
 
+var (
+	groupMaxAdmins int
+)
+
+func init() {
+	cmd.GlobalFlagSet.IntVar(&groupMaxAdmins, "group-max-admins", 10, "default max admins of a group")
+}
+
 type GroupService struct {
-	groupDao       *dao.GroupDao
-	groupMemberDao *dao.GroupMemberDao
-	userDao        *dao.UserDao
+	groupDao             *dao.GroupDao
+	groupMemberDao       *dao.GroupMemberDao
+	groupJoinRequestDao  *dao.GroupJoinRequestDao
+	groupInviteDao       *dao.GroupInviteDao
+	groupBanDao          *dao.GroupBanDao
+	groupAnnouncementDao *dao.GroupAnnouncementDao
+	userDao              *dao.UserDao
+	publisher            *event.Publisher
 
 	grouppb.UnimplementedGroupServiceServer
 }
@@ -30,9 +48,14 @@ var (
 func GetGroupService() *GroupService {
 	groupServiceOnce.Do(func() {
 		groupService = &GroupService{
-			groupDao:       dao.GetGroupDao(),
-			groupMemberDao: dao.GetGroupMemberDao(),
-			userDao:        dao.GetUserDao(),
+			groupDao:             dao.GetGroupDao(),
+			groupMemberDao:       dao.GetGroupMemberDao(),
+			groupJoinRequestDao:  dao.GetGroupJoinRequestDao(),
+			groupInviteDao:       dao.GetGroupInviteDao(),
+			groupBanDao:          dao.GetGroupBanDao(),
+			groupAnnouncementDao: dao.GetGroupAnnouncementDao(),
+			userDao:              dao.GetUserDao(),
+			publisher:            event.GetPublisher(),
 		}
 	})
 	return groupService
@@ -43,10 +66,7 @@ func (s *GroupService) GetGroup(ctx context.Context, req *grouppb.GetGroupReques
 		Error: errors.ErrorOK(),
 	}
 
-	var (
-		gid      = types.ID(req.Gid)
-		ownerUID = types.ID(req.OwnerUid)
-	)
+	gid := types.ID(req.Gid)
 
 	group, err := s.groupDao.GetGroupByGID(ctx, gid)
 	if err != nil {
@@ -60,11 +80,23 @@ func (s *GroupService) GetGroup(ctx context.Context, req *grouppb.GetGroupReques
 	}
 
 	rsp.Group = group.ToProto()
-	if !req.GetWithMembers() {
+
+	announcement, err := s.groupAnnouncementDao.GetCurrentGroupAnnouncement(ctx, group.GID)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
 		return rsp, nil
 	}
 
-	gmList, err := s.groupMemberDao.ListGroupMembersByGID(ctx, ownerUID)
+	if announcement != nil {
+		rsp.Group.Announcement = announcement.ToProto(false)
+	}
+
+	// members of super group are too many to load at once, they should be paged by ListGroupMembers.
+	if !req.GetWithMembers() || group.IsSuperGroup() {
+		return rsp, nil
+	}
+
+	gmList, err := s.groupMemberDao.ListGroupMembersByGID(ctx, group.GID)
 	if err != nil {
 		rsp.Error = errors.ErrorCode_DBError.WithError(err)
 		return rsp, nil
@@ -72,11 +104,11 @@ func (s *GroupService) GetGroup(ctx context.Context, req *grouppb.GetGroupReques
 
 	var (
 		uidList = make([]types.ID, len(gmList))
-		gmMap   = make(map[int64]*grouppb.GroupMember)
+		gmMap   = make(map[int64]*data.GroupMember)
 	)
 	for i, gm := range gmList {
 		uidList[i] = gm.UID
-		gmMap[gm.UID.Int64()] = gm.ToProto()
+		gmMap[gm.UID.Int64()] = gm
 	}
 
 	userList, err := s.userDao.ListUsers(ctx, uidList...)
@@ -87,14 +119,18 @@ func (s *GroupService) GetGroup(ctx context.Context, req *grouppb.GetGroupReques
 
 	for _, u := range userList {
 		gm := &grouppb.GroupMember{
-			Gid:  group.GID.Int64(),
-			Uid:  u.UID.Int64(),
-			User: u.ToProto(),
+			Gid:         group.GID.Int64(),
+			Uid:         u.UID.Int64(),
+			User:        u.ToProto(),
+			DisplayName: u.Name,
 		}
 
 		if temp, ok := gmMap[u.UID.Int64()]; ok {
 			gm.Type = temp.Type
 			gm.Status = temp.Status
+			gm.Nickname = temp.Nickname
+			gm.Title = temp.Title
+			gm.DisplayName = temp.DisplayName(u.Name)
 		}
 
 		rsp.Group.Members = append(rsp.Group.Members, gm)
@@ -136,17 +172,74 @@ func (s *GroupService) ListGroups(ctx context.Context, req *grouppb.ListGroupsRe
 }
 
 func (s *GroupService) CreateGroup(ctx context.Context, req *grouppb.CreateGroupRequest) (*grouppb.CreateGroupResponse, error) {
+	rsp := &grouppb.CreateGroupResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	tier, ok := groupTiers[req.Tier]
+	if !ok {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	// duplicated uids and the owner in MembersUid are ignored, otherwise member count drifts from member rows.
+	var (
+		ownerUID   = types.ID(req.OwnerUid)
+		seen       = util.NewSet[types.ID]()
+		memberUIDs = make([]types.ID, 0, len(req.MembersUid))
+	)
+	seen.Add(ownerUID)
+	for _, uid := range req.MembersUid {
+		id := types.ID(uid)
+		if seen.Contains(id) {
+			continue
+		}
+
+		seen.Add(id)
+		memberUIDs = append(memberUIDs, id)
+	}
+
+	if len(memberUIDs)+1 > tier.maxMembers {
+		rsp.Error = errors.ErrorCode_GroupLimitExceed.Err2()
+		return rsp, nil
+	}
+
+	tags, ok := data.NormalizeGroupTags(req.Tags)
+	if !ok {
+		rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("invalid tags")
+		return rsp, nil
+	}
+
+	if e := s.checkOwnedGroupLimit(ctx, ownerUID, req.Tier); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	// join policy is approval if not given, nobody can join the group without review.
 	group := &data.Group{
-		GID:         types.NewID(),
-		Name:        req.Name,
-		Description: req.Description,
-		Avatar:      req.Avatar,
-		OwnerUID:    types.ID(req.OwnerUid),
-		MaxMembers:  500, // todo: use config
-		MemberCount: len(req.MembersUid) + 1,
+		GID:          types.NewID(),
+		Name:         req.Name,
+		Description:  req.Description,
+		Avatar:       req.Avatar,
+		OwnerUID:     ownerUID,
+		Tier:         req.Tier,
+		MaxMembers:   tier.maxMembers,
+		MemberCount:  len(memberUIDs) + 1,
+		MaxAdmins:    groupMaxAdmins,
+		Public:       req.Public,
+		JoinPolicy:   req.JoinPolicy,
+		JoinQuestion: req.JoinQuestion,
+		JoinAnswer:   req.JoinAnswer,
+		ActiveAt:     time.Now().Unix(),
+	}
+	group.SetTags(tags)
+
+	if !group.ValidJoinPolicy() {
+		rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("invalid join policy")
+		return rsp, nil
 	}
 
-	var members = make([]*data.GroupMember, 0, len(req.MembersUid)+1)
+	var members = make([]*data.GroupMember, 0, len(memberUIDs)+1)
 
 	members = append(members, &data.GroupMember{
 		GID:  group.GID,
@@ -154,18 +247,15 @@ func (s *GroupService) CreateGroup(ctx context.Context, req *grouppb.CreateGroup
 		Type: grouppb.GroupMember_TypeOwner,
 	})
 
-	for _, uid := range req.MembersUid {
+	for _, uid := range memberUIDs {
 		members = append(members, &data.GroupMember{
-			GID:  group.GID,
-			UID:  types.ID(uid),
-			Type: grouppb.GroupMember_TypeMember,
+			GID:        group.GID,
+			UID:        uid,
+			Type:       grouppb.GroupMember_TypeMember,
+			InviterUID: group.OwnerUID,
 		})
 	}
 
-	rsp := &grouppb.CreateGroupResponse{
-		Error: errors.ErrorOK(),
-	}
-
 	err := db.Transaction(ctx, func(ctx2 context.Context) error {
 		if err := s.groupDao.CreateGroup(ctx2, group); err != nil {
 			return err
@@ -182,6 +272,8 @@ func (s *GroupService) CreateGroup(ctx context.Context, req *grouppb.CreateGroup
 		return rsp, nil
 	}
 
+	s.setMembersCache(ctx, group.GID, members...)
+
 	rsp.Group = group.ToProto()
 	return rsp, nil
 }
@@ -206,8 +298,8 @@ func (s *GroupService) UpdateGroup(ctx context.Context, req *grouppb.UpdateGroup
 		return rsp, nil
 	}
 
-	if group.OwnerUID.Int64() != req.OwnerUid {
-		rsp.Error = errors.ErrorCode_NotGroupOwner.Err2()
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OwnerUid), data.GroupPermissionEditInfo); e != nil {
+		rsp.Error = e
 		return rsp, nil
 	}
 
@@ -223,6 +315,37 @@ func (s *GroupService) UpdateGroup(ctx context.Context, req *grouppb.UpdateGroup
 		group.Avatar = req.GetAvatar()
 	}
 
+	if req.JoinPolicy != nil {
+		group.JoinPolicy = req.GetJoinPolicy()
+	}
+
+	if req.JoinQuestion != nil {
+		group.JoinQuestion = req.GetJoinQuestion()
+	}
+
+	if req.JoinAnswer != nil {
+		group.JoinAnswer = req.GetJoinAnswer()
+	}
+
+	if req.Public != nil {
+		group.Public = req.GetPublic()
+	}
+
+	if req.Tags != nil {
+		tags, ok := data.NormalizeGroupTags(req.Tags.Tags)
+		if !ok {
+			rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("invalid tags")
+			return rsp, nil
+		}
+
+		group.SetTags(tags)
+	}
+
+	if !group.ValidJoinPolicy() {
+		rsp.Error = errors.ErrorCode_InvalidParams.WithMessage("invalid join policy")
+		return rsp, nil
+	}
+
 	err = s.groupDao.UpdateGroup(ctx, group)
 	if err != nil {
 		rsp.Error = errors.ErrorCode_DBError.WithError(err)
@@ -233,6 +356,8 @@ func (s *GroupService) UpdateGroup(ctx context.Context, req *grouppb.UpdateGroup
 	return rsp, nil
 }
 
+// DeleteGroup dissolves group, the group is kept as tombstone and members are archived,
+// so that owner can restore it by RestoreGroup within restore window.
 func (s *GroupService) DeleteGroup(ctx context.Context, req *grouppb.DeleteGroupRequest) (*errors.Error, error) {
 	rsp := errors.ErrorOK()
 
@@ -247,17 +372,19 @@ func (s *GroupService) DeleteGroup(ctx context.Context, req *grouppb.DeleteGroup
 		return rsp, nil
 	}
 
-	if group.OwnerUID.Int64() != req.OwnerUid {
-		rsp = errors.ErrorCode_NotGroupOwner.Err2()
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OwnerUid), data.GroupPermissionDissolve); e != nil {
+		rsp = e
 		return rsp, nil
 	}
 
-	err = s.groupDao.DeleteGroup(ctx, group)
+	err = s.dissolveGroup(ctx, group, types.ID(req.OwnerUid))
 	if err != nil {
-		rsp = errors.ErrorCode_DBError.WithError(err)
+		rsp = txError(err)
 		return rsp, nil
 	}
 
+	s.purgeMembersCache(ctx, group.GID)
+
 	return rsp, nil
 }
 
@@ -285,18 +412,37 @@ func (s *GroupService) AddGroupMember(ctx context.Context, req *grouppb.ChangeGr
 		return rsp, nil
 	}
 
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OwnerUid), data.GroupPermissionAddMember); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	// dedupe uids, so that one user won't be added or counted twice.
+	seen := util.NewSet[types.ID]()
 	for _, uid := range req.Uids {
-		uids = append(uids, types.ID(uid))
+		id := types.ID(uid)
+		if seen.Contains(id) {
+			continue
+		}
+
+		seen.Add(id)
+		uids = append(uids, id)
 	}
 
-	uids, err = s.groupMemberDao.ListInGroupUIDs(ctx, group.GID, uids)
+	if e := s.checkNotBanned(ctx, group.GID, uids...); e != nil {
+		rsp.Error = e
+		return rsp, nil
+	}
+
+	inGroupUIDList, err := s.groupMemberDao.ListInGroupUIDs(ctx, group.GID, uids)
 	if err != nil {
 		rsp.Error = errors.ErrorCode_DBError.WithError(err)
 		return rsp, nil
 	}
 
 	// if all users are already in the group, return
-	if len(uids) == len(req.Uids) {
+	if len(inGroupUIDList) == len(uids) {
+		rsp.RemainingCapacity = int32(group.RemainingCapacity())
 		return rsp, nil
 	}
 
@@ -306,61 +452,81 @@ func (s *GroupService) AddGroupMember(ctx context.Context, req *grouppb.ChangeGr
 		newUIDs     []types.ID
 	)
 
-	for _, uid := range uids {
+	for _, uid := range inGroupUIDList {
 		inGroupUIDs.Add(uid)
 	}
 
-	for _, uid := range req.Uids {
-		id := types.ID(uid)
-		if !inGroupUIDs.Contains(id) {
-			newUIDs = append(newUIDs, id)
+	for _, uid := range uids {
+		if !inGroupUIDs.Contains(uid) {
+			newUIDs = append(newUIDs, uid)
 		}
 	}
 
 	// check if new users can add to the group, because the group has max member limit
 	if len(newUIDs)+group.MemberCount > group.MaxMembers {
 		rsp.Error = errors.ErrorCode_GroupLimitExceed.Err2()
+		rsp.RemainingCapacity = int32(group.RemainingCapacity())
 		return rsp, nil
 	}
 
-	var (
-		gmList = make([]*data.GroupMember, len(newUIDs))
-	)
+	var gmList []*data.GroupMember
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		var err1 error
+		gmList, err1 = s.addGroupMembers(ctx2, group, types.ID(req.OwnerUid), newUIDs)
+		return err1
+	})
+
+	if err != nil {
+		rsp.Error = txError(err)
+		return rsp, nil
+	}
+
+	s.setMembersCache(ctx, group.GID, gmList...)
+
+	group.MemberCount += len(newUIDs)
+	rsp.Count = int32(len(newUIDs))
+	rsp.RemainingCapacity = int32(group.RemainingCapacity())
+	return rsp, nil
+}
 
-	for i, uid := range newUIDs {
+// addGroupMembers increases the member count and creates members of given uids as ordinary members,
+// operatorUID is recorded as inviter of members except the one joined on their own.
+// Should be called in transaction, errors.ErrorCode_GroupLimitExceed is returned if group is full.
+func (s *GroupService) addGroupMembers(ctx context.Context, group *data.Group, operatorUID types.ID,
+	uids []types.ID) ([]*data.GroupMember, error) {
+	// increase the member count first
+	success, err := s.groupDao.IncrGroupMemberCount(ctx, group, uint(len(uids)))
+	if err != nil {
+		return nil, err
+	}
+
+	if !success {
+		return nil, errors.ErrorCode_GroupLimitExceed.Err2()
+	}
+
+	gmList := make([]*data.GroupMember, len(uids))
+	for i, uid := range uids {
 		gmList[i] = &data.GroupMember{
 			GID:  group.GID,
 			UID:  uid,
 			Type: grouppb.GroupMember_TypeMember,
 		}
-	}
-
-	// create group members and increase the member count
-	err = db.Transaction(ctx, func(ctx2 context.Context) error {
-		// increase the member count first
-		success, err1 := s.groupDao.IncrGroupMemberCount(ctx2, group, uint(len(newUIDs)))
-		if err1 != nil {
-			return err1
-		}
-
-		if !success {
-			return errors.ErrorCode_GroupLimitExceed.Err2()
-		}
 
-		if err1 = s.groupMemberDao.CreateGroupMember(ctx2, gmList...); err1 != nil {
-			return err1
+		if uid != operatorUID {
+			gmList[i].InviterUID = operatorUID
 		}
+	}
 
-		return nil
-	})
+	if err = s.groupMemberDao.CreateGroupMember(ctx, gmList...); err != nil {
+		return nil, err
+	}
 
-	if err != nil {
-		rsp.Error = errors.ErrorCode_DBError.WithError(err)
-		return rsp, nil
+	e := event.NewGroupEvent(eventv1.GroupEventType_GROUP_MEMBERS_ADDED, group.GID, operatorUID, uids...)
+	if err = s.publisher.EnqueueGroupEvent(ctx, e); err != nil {
+		return nil, err
 	}
 
-	rsp.Count = int32(len(newUIDs))
-	return rsp, nil
+	return gmList, nil
 }
 
 func (s *GroupService) RemoveGroupMember(ctx context.Context, req *grouppb.ChangeGroupMemberRequest) (
@@ -370,9 +536,9 @@ func (s *GroupService) RemoveGroupMember(ctx context.Context, req *grouppb.Chang
 	}
 
 	var (
-		gid      = types.ID(req.Gid)
-		ownerUID = types.ID(req.OwnerUid)
-		uids     = make([]types.ID, 0, len(req.Uids))
+		gid         = types.ID(req.Gid)
+		operatorUID = types.ID(req.OwnerUid)
+		uids        = make([]types.ID, 0, len(req.Uids))
 	)
 
 	group, err := s.groupDao.GetGroupByGID(ctx, gid)
@@ -386,76 +552,415 @@ func (s *GroupService) RemoveGroupMember(ctx context.Context, req *grouppb.Chang
 		return rsp, nil
 	}
 
-	if group.OwnerUID != ownerUID {
-		rsp.Error = errors.ErrorCode_NotGroupOwner.Err2()
+	operator, e := s.checkPermission(ctx, group, operatorUID, data.GroupPermissionRemoveMember)
+	if e != nil {
+		rsp.Error = e
 		return rsp, nil
 	}
 
-	uids, err = s.groupMemberDao.ListInGroupUIDs(ctx, group.GID, uids)
+	for _, uid := range req.Uids {
+		uids = append(uids, types.ID(uid))
+	}
+
+	// users not in the group are filtered out
+	gmList, err := s.groupMemberDao.ListGroupMembersByUIDs(ctx, group.GID, uids)
 	if err != nil {
 		rsp.Error = errors.ErrorCode_DBError.WithError(err)
 		return rsp, nil
 	}
 
 	// if all users are not in the group, return
-	if len(uids) == 0 {
+	if len(gmList) == 0 {
+		return rsp, nil
+	}
+
+	needRemoveUIDs := make([]types.ID, 0, len(gmList))
+	for _, gm := range gmList {
+		// owner can not be removed, and admin can not remove other admins.
+		if !operator.CanManage(gm) {
+			rsp.Error = errors.ErrorCode_GroupPermissionDenied.Err2()
+			return rsp, nil
+		}
+
+		needRemoveUIDs = append(needRemoveUIDs, gm.UID)
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		return s.removeGroupMembers(ctx2, group, needRemoveUIDs)
+	})
+
+	if err != nil {
+		rsp.Error = txError(err)
 		return rsp, nil
 	}
 
-	// filter out the users not in the group
+	s.setNonMembersCache(ctx, group.GID, needRemoveUIDs...)
+
+	rsp.Count = int32(len(needRemoveUIDs))
+	return rsp, nil
+}
+
+// removeGroupMembers deletes group members and decreases the member count, should be called in transaction.
+func (s *GroupService) removeGroupMembers(ctx context.Context, group *data.Group, uids []types.ID) error {
+	// decrease the member count first
+	success, err := s.groupDao.DecrGroupMemberCount(ctx, group, uint(len(uids)))
+	if err != nil {
+		return err
+	}
+
+	if !success {
+		return errors.ErrorCode_GroupLimitExceed.Err2()
+	}
+
+	return s.groupMemberDao.DeleteGroupMembers(ctx, group.GID, uids)
+}
+
+// LeaveGroup removes caller from group.
+// Owner must either transfer the group to a successor or dissolve it before leaving.
+func (s *GroupService) LeaveGroup(ctx context.Context, req *grouppb.LeaveGroupRequest) (*errors.Error, error) {
 	var (
-		inGroupUIDs    = util.NewSet[types.ID]()
-		needRemoveUIDs []types.ID
+		gid = types.ID(req.Gid)
+		uid = types.ID(req.Uid)
 	)
 
-	for _, uid := range uids {
-		inGroupUIDs.Add(uid)
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
 	}
 
-	for _, uid := range req.Uids {
-		id := types.ID(uid)
-		if inGroupUIDs.Contains(id) {
-			needRemoveUIDs = append(needRemoveUIDs, id)
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, uid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if gm == nil {
+		return errors.ErrorCode_NotGroupMember.Err2(), nil
+	}
+
+	// successor of owner, ownership is transferred in the same transaction of leaving.
+	var newOwner *data.GroupMember
+	if gm.IsOwner() {
+		switch {
+		case req.GetDissolve():
+			return s.DeleteGroup(ctx, &grouppb.DeleteGroupRequest{
+				Gid:      req.Gid,
+				OwnerUid: req.Uid,
+			})
+		case req.GetNewOwnerUid() != 0:
+			if req.GetNewOwnerUid() == req.Uid {
+				return errors.ErrorCode_InvalidParams.Err2(), nil
+			}
+
+			var e *errors.Error
+			if newOwner, e = s.getNewGroupOwner(ctx, group, types.ID(req.GetNewOwnerUid())); e != nil {
+				return e, nil
+			}
+		default:
+			return errors.ErrorCode_GroupOwnerCannotLeave.Err2(), nil
 		}
 	}
 
-	// delete group members and decrease the member count
 	err = db.Transaction(ctx, func(ctx2 context.Context) error {
-		// decrease the member count first
-		success, err1 := s.groupDao.DecrGroupMemberCount(ctx2, group, uint(len(needRemoveUIDs)))
+		if newOwner != nil {
+			if err1 := s.transferGroupOwnership(ctx2, group, uid, newOwner); err1 != nil {
+				return err1
+			}
+		}
+
+		success, err1 := s.groupDao.DecrGroupMemberCount(ctx2, group, 1)
 		if err1 != nil {
 			return err1
 		}
 
 		if !success {
-			return errors.ErrorCode_GroupLimitExceed
+			return errors.ErrorCode_GroupLimitExceed.Err2()
 		}
 
-		if err1 = s.groupMemberDao.DeleteGroupMembers(ctx2, group.GID, needRemoveUIDs); err1 != nil {
+		if err1 = s.groupMemberDao.DeleteGroupMembers(ctx2, group.GID, []types.ID{uid}); err1 != nil {
 			return err1
 		}
 
-		return nil
+		e := event.NewGroupEvent(eventv1.GroupEventType_GROUP_MEMBER_LEFT, group.GID, uid, uid)
+		return s.publisher.EnqueueGroupEvent(ctx2, e)
+	})
+	if err != nil {
+		return txError(err), nil
+	}
+
+	s.setNonMembersCache(ctx, group.GID, uid)
+	return errors.ErrorOK(), nil
+}
+
+// getNewGroupOwner loads the member who will own the group and checks whether they can own one more group.
+func (s *GroupService) getNewGroupOwner(ctx context.Context, group *data.Group, newOwnerUID types.ID) (
+	*data.GroupMember, *errors.Error) {
+	target, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, newOwnerUID)
+	if err != nil {
+		return nil, errors.ErrorCode_DBError.WithError(err)
+	}
+
+	// mute only restricts sending messages, muted member can still be owner.
+	if target == nil {
+		return nil, errors.ErrorCode_NotGroupMember.Err2()
+	}
+
+	if e := s.checkOwnedGroupLimit(ctx, newOwnerUID, group.Tier); e != nil {
+		return nil, e
+	}
+
+	return target, nil
+}
+
+// transferGroupOwnership swaps member type of owner and target and changes owner of group.
+// Should be called in transaction, errors.ErrorCode_NotGroupOwner is returned if owner changed concurrently.
+func (s *GroupService) transferGroupOwnership(ctx context.Context, group *data.Group, ownerUID types.ID,
+	target *data.GroupMember) error {
+	ok, err := s.groupDao.UpdateGroupOwner(ctx, group.GID, ownerUID, target.UID)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.ErrorCode_NotGroupOwner.Err2()
+	}
+
+	err = s.groupMemberDao.UpdateGroupMembersType(ctx, group.GID, []types.ID{ownerUID}, target.Type)
+	if err != nil {
+		return err
+	}
+
+	err = s.groupMemberDao.UpdateGroupMembersType(ctx, group.GID, []types.ID{target.UID}, grouppb.GroupMember_TypeOwner)
+	if err != nil {
+		return err
+	}
+
+	// types of both members are swapped, uids are new owner and old owner in order.
+	e := event.NewGroupEvent(eventv1.GroupEventType_GROUP_OWNERSHIP_TRANSFERRED, group.GID, ownerUID,
+		target.UID, ownerUID)
+	return s.publisher.EnqueueGroupEvent(ctx, e)
+}
+
+// TransferGroupOwnership transfers group from owner to another active member.
+// Types of the owner and new owner are swapped, so the old owner takes the role new owner had before.
+func (s *GroupService) TransferGroupOwnership(ctx context.Context, req *grouppb.TransferGroupOwnershipRequest) (
+	*errors.Error, error) {
+	var (
+		gid         = types.ID(req.Gid)
+		ownerUID    = types.ID(req.OwnerUid)
+		newOwnerUID = types.ID(req.NewOwnerUid)
+	)
+
+	if ownerUID == newOwnerUID {
+		return errors.ErrorCode_InvalidParams.Err2(), nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err), nil
+	}
+
+	if group == nil {
+		return errors.ErrorCode_GroupNotExist.Err2(), nil
+	}
+
+	if group.OwnerUID != ownerUID {
+		return errors.ErrorCode_NotGroupOwner.Err2(), nil
+	}
+
+	target, e := s.getNewGroupOwner(ctx, group, newOwnerUID)
+	if e != nil {
+		return e, nil
+	}
+
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		return s.transferGroupOwnership(ctx2, group, ownerUID, target)
 	})
+	if err != nil {
+		return txError(err), nil
+	}
+
+	owner, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, ownerUID)
+	if err != nil || owner == nil {
+		// owner status unknown, reload members lazily.
+		s.purgeMembersCache(ctx, group.GID)
+		return errors.ErrorOK(), nil
+	}
+
+	s.setMembersCache(ctx, group.GID, owner, target)
+	return errors.ErrorOK(), nil
+}
 
+// AddGroupAdmin promotes members to admins of group, only owner can manage admins.
+func (s *GroupService) AddGroupAdmin(ctx context.Context, req *grouppb.ChangeGroupMemberRequest) (
+	*grouppb.ChangeGroupMemberResponse, error) {
+	return s.changeGroupMembersType(ctx, req, grouppb.GroupMember_TypeMember, grouppb.GroupMember_TypeAdmin), nil
+}
+
+// RemoveGroupAdmin demotes admins to ordinary members of group, only owner can manage admins.
+func (s *GroupService) RemoveGroupAdmin(ctx context.Context, req *grouppb.ChangeGroupMemberRequest) (
+	*grouppb.ChangeGroupMemberResponse, error) {
+	return s.changeGroupMembersType(ctx, req, grouppb.GroupMember_TypeAdmin, grouppb.GroupMember_TypeMember), nil
+}
+
+// changeGroupMembersType changes type of members in req.Uids from given type to another.
+// Members not in the group or not of the from type are ignored.
+func (s *GroupService) changeGroupMembersType(ctx context.Context, req *grouppb.ChangeGroupMemberRequest,
+	from, to grouppb.GroupMember_Type) *grouppb.ChangeGroupMemberResponse {
+	rsp := &grouppb.ChangeGroupMemberResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	var (
+		gid  = types.ID(req.Gid)
+		uids = make([]types.ID, 0, len(req.Uids))
+	)
+
+	group, err := s.groupDao.GetGroupByGID(ctx, gid)
 	if err != nil {
 		rsp.Error = errors.ErrorCode_DBError.WithError(err)
-		return rsp, nil
+		return rsp
 	}
 
-	rsp.Count = int32(len(needRemoveUIDs))
-	return rsp, nil
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp
+	}
+
+	if _, e := s.checkPermission(ctx, group, types.ID(req.OwnerUid), data.GroupPermissionManageAdmin); e != nil {
+		rsp.Error = e
+		return rsp
+	}
+
+	for _, uid := range req.Uids {
+		uids = append(uids, types.ID(uid))
+	}
+
+	gmList, err := s.groupMemberDao.ListGroupMembersByUIDs(ctx, group.GID, uids)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp
+	}
+
+	var targets []types.ID
+	for _, gm := range gmList {
+		if gm.Type == from {
+			targets = append(targets, gm.UID)
+		}
+	}
+
+	if len(targets) == 0 {
+		return rsp
+	}
+
+	var limitExceeded bool
+	err = db.Transaction(ctx, func(ctx2 context.Context) error {
+		if to == grouppb.GroupMember_TypeAdmin {
+			// lock the group row, so that concurrent promotions won't exceed the admin limit.
+			g, err1 := s.groupDao.GetGroupByGIDForUpdate(ctx2, group.GID)
+			if err1 != nil {
+				return err1
+			}
+
+			count, err1 := s.groupMemberDao.CountGroupMembersByType(ctx2, group.GID, grouppb.GroupMember_TypeAdmin)
+			if err1 != nil {
+				return err1
+			}
+
+			if int(count)+len(targets) > maxAdminsOfGroup(g) {
+				limitExceeded = true
+				return nil
+			}
+		}
+
+		return s.groupMemberDao.UpdateGroupMembersType(ctx2, group.GID, targets, to)
+	})
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp
+	}
+
+	if limitExceeded {
+		rsp.Error = errors.ErrorCode_GroupAdminLimitExceed.Err2()
+		return rsp
+	}
+
+	rsp.Count = int32(len(targets))
+	return rsp
 }
 
-func (s *GroupService) isInGroup(ctx context.Context, gid, uid types.ID) (bool, error) {
-	gm, err := s.groupMemberDao.IsMemberOfGroup(ctx, gid, uid)
+// maxAdminsOfGroup returns admin limit of group, groups created before admin role introduced use default limit.
+func maxAdminsOfGroup(g *data.Group) int {
+	if g.MaxAdmins > 0 {
+		return g.MaxAdmins
+	}
+
+	return groupMaxAdmins
+}
+
+// checkPermission checks whether uid has given permission in group, the operator is returned if permitted.
+func (s *GroupService) checkPermission(ctx context.Context, group *data.Group, uid types.ID,
+	perm data.GroupPermission) (*data.GroupMember, *errors.Error) {
+	gm, err := s.groupMemberDao.GetGroupMemberByGIDUID(ctx, group.GID, uid)
 	if err != nil {
-		return false, err
+		return nil, errors.ErrorCode_DBError.WithError(err)
 	}
 
-	if gm == nil {
-		return false, nil
+	if gm == nil || !gm.HasPermission(perm) {
+		return nil, errors.ErrorCode_GroupPermissionDenied.Err2()
+	}
+
+	return gm, nil
+}
+
+// txError converts error returned by transaction to response error, errors.Error returned in transaction is kept.
+func txError(err error) *errors.Error {
+	if e, ok := err.(*errors.Error); ok {
+		return e
+	}
+
+	return errors.ErrorCode_DBError.WithError(err)
+}
+
+// setMembersCache sets status of members to group members cache after membership changed in db.
+// The whole cache of group is purged if failed, so that it will be reloaded from db lazily.
+func (s *GroupService) setMembersCache(ctx context.Context, gid types.ID, members ...*data.GroupMember) {
+	statuses := make(map[types.ID]int, len(members))
+	for _, gm := range members {
+		statuses[gm.UID] = int(gm.Status)
+	}
+
+	if err := s.groupMemberDao.SetMembersStatusToCache(ctx, gid, statuses); err != nil {
+		log.Error("set members status to cache error", "gid", gid, "err", err)
+		s.purgeMembersCache(ctx, gid)
+		return
 	}
 
-	return gm.Status == grouppb.GroupMember_StatusActive, nil
+	if err := s.groupMemberDao.AddToGroupMemberUIDSet(ctx, gid, members...); err != nil {
+		log.Error("add members to member uid set error", "gid", gid, "err", err)
+		s.purgeMembersCache(ctx, gid)
+	}
+}
+
+// setNonMembersCache marks removed members as non-members in group members cache.
+func (s *GroupService) setNonMembersCache(ctx context.Context, gid types.ID, uids ...types.ID) {
+	if err := s.groupMemberDao.SetNonMembersToCache(ctx, gid, uids...); err != nil {
+		log.Error("set non-members to cache error", "gid", gid, "err", err)
+		s.purgeMembersCache(ctx, gid)
+		return
+	}
+
+	if err := s.groupMemberDao.RemoveFromGroupMemberUIDSet(ctx, gid, uids...); err != nil {
+		log.Error("remove members from member uid set error", "gid", gid, "err", err)
+		s.purgeMembersCache(ctx, gid)
+	}
+}
+
+func (s *GroupService) purgeMembersCache(ctx context.Context, gid types.ID) {
+	if err := s.groupMemberDao.DeleteGroupMembersCache(ctx, gid); err != nil {
+		log.Error("delete group members cache error", "gid", gid, "err", err)
+	}
 }
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-goim/api/errors"
+	grouppb "github.com/go-goim/api/user/group/v1"
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/types"
+)
+
+// groupTierConfig is the size limits of a group tier.
+type groupTierConfig struct {
+	// maxMembers is max members of groups in this tier.
+	maxMembers int
+	// maxOwned is max count of groups in this tier a user can own, 0 means unlimited.
+	maxOwned int
+}
+
+var (
+	groupTiers = map[grouppb.GroupTier]*groupTierConfig{
+		grouppb.GroupTier_Standard: {},
+		grouppb.GroupTier_Large:    {},
+		grouppb.GroupTier_Super:    {},
+	}
+)
+
+func init() {
+	standard := groupTiers[grouppb.GroupTier_Standard]
+	cmd.GlobalFlagSet.IntVar(&standard.maxMembers, "group-tier-standard-max-members", 500,
+		"max members of standard group")
+	cmd.GlobalFlagSet.IntVar(&standard.maxOwned, "group-tier-standard-max-owned", 20,
+		"max count of standard groups a user can own, 0 means unlimited")
+
+	large := groupTiers[grouppb.GroupTier_Large]
+	cmd.GlobalFlagSet.IntVar(&large.maxMembers, "group-tier-large-max-members", 2000,
+		"max members of large group")
+	cmd.GlobalFlagSet.IntVar(&large.maxOwned, "group-tier-large-max-owned", 5,
+		"max count of large groups a user can own, 0 means unlimited")
+
+	super := groupTiers[grouppb.GroupTier_Super]
+	cmd.GlobalFlagSet.IntVar(&super.maxMembers, "group-tier-super-max-members", 10000,
+		"max members of super group")
+	cmd.GlobalFlagSet.IntVar(&super.maxOwned, "group-tier-super-max-owned", 1,
+		"max count of super groups a user can own, 0 means unlimited")
+}
+
+// checkOwnedGroupLimit checks whether uid can own one more group of given tier.
+func (s *GroupService) checkOwnedGroupLimit(ctx context.Context, uid types.ID, tier grouppb.GroupTier) *errors.Error {
+	cfg, ok := groupTiers[tier]
+	if !ok {
+		return errors.ErrorCode_InvalidParams.Err2()
+	}
+
+	if cfg.maxOwned == 0 {
+		return nil
+	}
+
+	count, err := s.groupDao.CountOwnedGroups(ctx, uid, tier)
+	if err != nil {
+		return errors.ErrorCode_DBError.WithError(err)
+	}
+
+	if count >= int64(cfg.maxOwned) {
+		return errors.ErrorCode_GroupOwnedLimitExceed.Err2()
+	}
+
+	return nil
+}
+
+// ChangeGroupTier moves group to another tier, max members of group is changed to the one of new tier.
+// Owner can change tier of their group within their owned group limit of new tier,
+// system admins can change tier of any group regardless of the limit.
+func (s *GroupService) ChangeGroupTier(ctx context.Context, req *grouppb.ChangeGroupTierRequest) (
+	*grouppb.ChangeGroupTierResponse, error) {
+	rsp := &grouppb.ChangeGroupTierResponse{
+		Error: errors.ErrorOK(),
+	}
+
+	cfg, ok := groupTiers[req.Tier]
+	if !ok {
+		rsp.Error = errors.ErrorCode_InvalidParams.Err2()
+		return rsp, nil
+	}
+
+	group, err := s.groupDao.GetGroupByGID(ctx, types.ID(req.Gid))
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	if group == nil {
+		rsp.Error = errors.ErrorCode_GroupNotExist.Err2()
+		return rsp, nil
+	}
+
+	operatorUID := types.ID(req.OperatorUid)
+	if !isSystemAdmin(operatorUID) {
+		if group.OwnerUID != operatorUID {
+			rsp.Error = errors.ErrorCode_GroupPermissionDenied.Err2()
+			return rsp, nil
+		}
+
+		if group.Tier != req.Tier {
+			if e := s.checkOwnedGroupLimit(ctx, group.OwnerUID, req.Tier); e != nil {
+				rsp.Error = e
+				return rsp, nil
+			}
+		}
+	}
+
+	if group.MemberCount > cfg.maxMembers {
+		rsp.Error = errors.ErrorCode_GroupLimitExceed.Err2()
+		return rsp, nil
+	}
+
+	ok, err = s.groupDao.UpdateGroupTier(ctx, group.GID, req.Tier, cfg.maxMembers)
+	if err != nil {
+		rsp.Error = errors.ErrorCode_DBError.WithError(err)
+		return rsp, nil
+	}
+
+	// members joined or group dissolved concurrently
+	if !ok {
+		rsp.Error = errors.ErrorCode_GroupLimitExceed.Err2()
+		return rsp, nil
+	}
+
+	group.Tier = req.Tier
+	group.MaxMembers = cfg.maxMembers
+	rsp.Group = group.ToProto()
+	return rsp, nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/go-goim/core/pkg/cmd"
+	"github.com/go-goim/core/pkg/types"
+)
+
+var (
+	systemAdminUIDs []string
+)
+
+func init() {
+	cmd.GlobalFlagSet.StringSliceVar(&systemAdminUIDs, "system-admin-uids", nil,
+		"uids of system admins who can run admin operations like changing group tier or reconciling member counts")
+}
+
+// isSystemAdmin checks whether uid is configured as system admin.
+func isSystemAdmin(uid types.ID) bool {
+	for _, s := range systemAdminUIDs {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil && types.ID(i) == uid {
+			return true
+		}
+	}
+
+	return false
+}